@@ -1,18 +1,80 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"raytracer/internal/camera"
 	"raytracer/internal/color"
 	"raytracer/internal/core"
 	"raytracer/internal/hittable"
 	"raytracer/internal/material"
+	"raytracer/internal/sampler"
 	"raytracer/internal/util"
 	"raytracer/internal/vector"
+	"syscall"
+	"time"
 )
 
 func main() {
+	seed := flag.Uint64("seed", 0, "seed for the scene layout and sampling; equal seeds give identical images")
+	samplerName := flag.String("sampler", "independent", "pixel sampler: independent, stratified, halton, sobol or bluenoise")
+	filterName := flag.String("filter", "box", "pixel reconstruction filter: box, tent, gaussian, mitchell or lanczos")
+	filterRadius := flag.Float64("filter-radius", 0, "filter radius in pixels (default depends on the filter)")
+	adaptive := flag.Float64("adaptive", 0, "stop sampling a pixel once its brightness is known to within this amount (0 to 1); 0 disables")
+	minSamples := flag.Int("min-samples", 0, "samples every pixel takes before adaptive sampling may stop it (default 16)")
+	heatmapPath := flag.String("heatmap", "", "write a PPM image of the samples each pixel took to this file")
+	tileSize := flag.Int("tile-size", 0, "width and height of render tiles in pixels (default 32)")
+	tileOrderName := flag.String("tile-order", "spiral", "order to render tiles in: row, spiral or hilbert")
+	passSamples := flag.Int("pass-samples", 0, "render progressively in passes of this many samples per pixel (default 4 with -snapshot, -time-limit or -checkpoint, otherwise one pass)")
+	snapshotPath := flag.String("snapshot", "", "write the image so far to this file after each pass")
+	snapshotInterval := flag.Duration("snapshot-interval", 10*time.Second, "minimum time between snapshots")
+	timeLimit := flag.Duration("time-limit", 0, "stop rendering after the first pass that ends past this time")
+	checkpointPath := flag.String("checkpoint", "", "save progress to this file after passes, at most once per -checkpoint-interval, and when rendering stops early")
+	checkpointInterval := flag.Duration("checkpoint-interval", 5*time.Minute, "minimum time between checkpoints")
+	resumePath := flag.String("resume", "", "continue rendering from this checkpoint, using the same flags as before")
+	progressStyle := flag.String("progress", "bar", "progress reporting on stderr: bar, json or none")
+	flag.Parse()
+
+	// fail reports err on stderr, as a JSON line alongside JSON progress, and
+	// exits with a failure status.
+	fail := func(err error) {
+		if *progressStyle == "json" {
+			json.NewEncoder(os.Stderr).Encode(jsonMessage{Type: "error", Message: err.Error()})
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+
+	samplerKind, err := sampler.ParseKind(*samplerName)
+	if err != nil {
+		fail(fmt.Errorf("invalid flags: %w", err))
+	}
+	filter, err := camera.ParseFilter(*filterName)
+	if err != nil {
+		fail(fmt.Errorf("invalid flags: %w", err))
+	}
+	tileOrder, err := camera.ParseTileOrder(*tileOrderName)
+	if err != nil {
+		fail(fmt.Errorf("invalid flags: %w", err))
+	}
+	progress, err := progressReporter(*progressStyle, os.Stderr)
+	if err != nil {
+		fail(fmt.Errorf("invalid flags: %w", err))
+	}
+
+	// The scene is generated from its own random stream, separate from the
+	// camera's sampling.
+	rng := util.NewRNG(*seed, math.MaxUint64)
+
 	world := hittable.NewHittableList()
 
 	groundMaterial := material.NewLambertian(color.NewColor(0.5, 0.5, 0.5))
@@ -20,19 +82,19 @@ func main() {
 
 	for a := -11; a < 11; a++ {
 		for b := -11; b < 11; b++ {
-			chooseMat := util.RandomFloat()
-			center := vector.NewPoint3(float64(a)+0.9*util.RandomFloat(), 0.2, float64(b)+0.9*util.RandomFloat())
+			chooseMat := util.RandomFloat(rng)
+			center := vector.NewPoint3(float64(a)+0.9*util.RandomFloat(rng), 0.2, float64(b)+0.9*util.RandomFloat(rng))
 
 			var sphereMaterial core.Material
 
 			if chooseMat < 0.8 {
 				// Diffuse
-				albedo := color.Random().Mul(color.Random())
+				albedo := color.Random(rng).Mul(color.Random(rng))
 				sphereMaterial = material.NewLambertian(albedo)
 			} else if chooseMat < 0.95 {
 				// Metal
-				albedo := color.RandomFromRange(0.5, 1)
-				fuzz := util.RandomFloatFromRange(0, 0.5)
+				albedo := color.RandomFromRange(rng, 0.5, 1)
+				fuzz := util.RandomFloatFromRange(rng, 0, 0.5)
 				sphereMaterial = material.NewMetal(albedo, fuzz)
 			} else {
 				// Glass
@@ -67,11 +129,82 @@ func main() {
 	camConfig.DefocusAngle = 0.6
 	camConfig.FocusDist = 10.0
 
+	camConfig.Seed = *seed
+	camConfig.Sampler = samplerKind
+	camConfig.Filter = filter
+	camConfig.FilterRadius = *filterRadius
+	camConfig.AdaptiveThreshold = *adaptive
+	camConfig.MinSamples = *minSamples
+
+	if *heatmapPath != "" {
+		heatmap, err := os.Create(*heatmapPath)
+		if err != nil {
+			fail(fmt.Errorf("failed to create heatmap file: %w", err))
+		}
+		defer heatmap.Close()
+		camConfig.SampleHeatmap = heatmap
+	}
+
+	camConfig.TileSize = *tileSize
+	camConfig.TileOrder = tileOrder
+	camConfig.Progress = progress
+	camConfig.PassSamples = *passSamples
+	camConfig.CheckpointPath = *checkpointPath
+	camConfig.CheckpointInterval = *checkpointInterval
+	camConfig.ResumeFrom = *resumePath
+	camConfig.TimeLimit = *timeLimit
+	if *snapshotPath != "" {
+		camConfig.Snapshot = func(image *camera.Framebuffer, samples int) error {
+			return writeSnapshot(*snapshotPath, image)
+		}
+		camConfig.SnapshotInterval = *snapshotInterval
+	}
+
 	cam, err := camera.New(camConfig)
 	if err != nil {
-		fmt.Print(fmt.Errorf("failed to create camera: %w", err))
-		return
+		fail(fmt.Errorf("failed to create camera: %w", err))
+	}
+
+	// Stop on Ctrl-C or termination, still writing out the image rendered so
+	// far and saving a checkpoint if asked to.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Keep stderr to JSON lines alone when they're asked for.
+	var log io.Writer = os.Stderr
+	if *progressStyle == "json" {
+		log = jsonLog{json.NewEncoder(os.Stderr)}
+	}
+
+	stats, err := cam.Render(ctx, os.Stdout, log, world)
+	if *progressStyle == "json" {
+		json.NewEncoder(os.Stderr).Encode(statsJSON(stats))
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// writeSnapshot writes image to path as a PPM. It writes a temporary file
+// first and renames it, so that viewers never see a partial image.
+func writeSnapshot(path string, image *camera.Framebuffer) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
 	}
+	defer os.Remove(tmp.Name())
 
-	cam.Render(os.Stdout, os.Stderr, world)
+	w := bufio.NewWriter(tmp)
+	if err := image.WritePPM(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"raytracer/internal/camera"
+	"strings"
+	"time"
+)
+
+// progressReporter returns a camera progress callback that writes to w in the
+// named style: "bar" for a progress bar redrawn in place, "json" for one JSON
+// object per line, or "none".
+func progressReporter(style string, w io.Writer) (func(camera.Progress), error) {
+	switch style {
+	case "bar":
+		return throttled(100*time.Millisecond, func(p camera.Progress) {
+			writeProgressBar(w, p)
+		}), nil
+	case "json":
+		enc := json.NewEncoder(w)
+		return throttled(time.Second, func(p camera.Progress) {
+			enc.Encode(progressJSON(p))
+		}), nil
+	case "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown progress style %q", style)
+}
+
+// throttled calls report at most once per interval, and always for the last
+// tile.
+func throttled(interval time.Duration, report func(camera.Progress)) func(camera.Progress) {
+	var last time.Time
+	return func(p camera.Progress) {
+		if p.TilesDone < p.Tiles && time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		report(p)
+	}
+}
+
+const progressBarWidth = 30
+
+func writeProgressBar(w io.Writer, p camera.Progress) {
+	filled := int(p.Fraction() * progressBarWidth)
+	eta := "--"
+	if p.ETA > 0 {
+		eta = p.ETA.Round(time.Second).String()
+	}
+	fmt.Fprintf(w, "\r[%s%s] %5.1f%%  %d/%d tiles  %s rays  %s elapsed  ETA %s\033[K",
+		strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled),
+		100*p.Fraction(), p.TilesDone, p.Tiles, siCount(p.Rays),
+		p.Elapsed.Round(time.Second), eta)
+}
+
+// siCount formats n with a metric suffix, such as 12.3M.
+func siCount(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fG", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	}
+	return fmt.Sprint(n)
+}
+
+// jsonProgress is the machine-readable form of camera.Progress.
+type jsonProgress struct {
+	Type           string  `json:"type"` // Always "progress"
+	TilesDone      int     `json:"tiles_done"`
+	Tiles          int     `json:"tiles"`
+	Fraction       float64 `json:"fraction"`
+	Samples        int64   `json:"samples"`
+	Rays           int64   `json:"rays"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	ETASeconds     float64 `json:"eta_seconds"`
+}
+
+func progressJSON(p camera.Progress) jsonProgress {
+	return jsonProgress{
+		Type:           "progress",
+		TilesDone:      p.TilesDone,
+		Tiles:          p.Tiles,
+		Fraction:       p.Fraction(),
+		Samples:        p.Samples,
+		Rays:           p.Rays,
+		ElapsedSeconds: p.Elapsed.Seconds(),
+		ETASeconds:     p.ETA.Seconds(),
+	}
+}
+
+// jsonStats is the machine-readable form of camera.Stats, written once the
+// render ends, before any error.
+type jsonStats struct {
+	Type                 string  `json:"type"` // Always "stats"
+	Seconds              float64 `json:"seconds"`
+	Samples              int64   `json:"samples"`
+	Rays                 int64   `json:"rays"`
+	RaysPerSecond        float64 `json:"rays_per_second"`
+	PrimaryRays          int64   `json:"primary_rays"`
+	SecondaryRays        int64   `json:"secondary_rays"`
+	ShadowRays           int64   `json:"shadow_rays"`
+	AveragePathDepth     float64 `json:"average_path_depth"`
+	MaxDepthTerminations int64   `json:"max_depth_terminations"`
+	IntersectionTests    int64   `json:"intersection_tests"`
+	TestsPerRay          float64 `json:"tests_per_ray"`
+	NodeVisits           int64   `json:"node_visits"`
+}
+
+func statsJSON(st camera.Stats) jsonStats {
+	return jsonStats{
+		Type:                 "stats",
+		Seconds:              st.Duration.Seconds(),
+		Samples:              st.Samples,
+		Rays:                 st.Rays(),
+		RaysPerSecond:        st.RaysPerSecond(),
+		PrimaryRays:          st.PrimaryRays,
+		SecondaryRays:        st.SecondaryRays,
+		ShadowRays:           st.ShadowRays,
+		AveragePathDepth:     st.AveragePathDepth(),
+		MaxDepthTerminations: st.MaxDepthTerminations,
+		IntersectionTests:    st.IntersectionTests,
+		TestsPerRay:          st.TestsPerRay(),
+		NodeVisits:           st.NodeVisits,
+	}
+}
+
+// jsonMessage is a notice or error, written as a JSON line alongside progress.
+type jsonMessage struct {
+	Type    string `json:"type"` // "notice" or "error"
+	Message string `json:"message"`
+}
+
+// jsonLog writes each message logged to it, such as the camera's notices, as a
+// JSON notice line.
+type jsonLog struct {
+	enc *json.Encoder
+}
+
+func (l jsonLog) Write(p []byte) (int, error) {
+	if message := strings.TrimSpace(string(p)); message != "" {
+		if err := l.enc.Encode(jsonMessage{Type: "notice", Message: message}); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,65 @@
+package camera
+
+import (
+	"math"
+	"raytracer/internal/color"
+)
+
+// defaultMinSamples is how many samples a pixel takes before adaptive sampling
+// may stop it, when Config.MinSamples isn't set.
+const defaultMinSamples = 16
+
+// pixelStats tracks the running mean and variance of the luminance of a
+// pixel's samples, with Welford's algorithm.
+type pixelStats struct {
+	n    int
+	mean float64
+	m2   float64 // Sum of squared differences from the mean
+	done bool    // Whether adaptive sampling has stopped the pixel
+}
+
+func (ps *pixelStats) add(sample color.Color) {
+	ps.n++
+	y := luminance(sample)
+	d := y - ps.mean
+	ps.mean += d / float64(ps.n)
+	ps.m2 += d * (y - ps.mean)
+}
+
+// converged reports whether the pixel's displayed brightness is known to
+// within threshold, with 95% confidence. The error is measured after gamma
+// encoding, so dark pixels need less absolute precision than bright ones, just
+// as the eye does.
+func (ps *pixelStats) converged(threshold float64) bool {
+	if ps.n < 2 {
+		return false
+	}
+	variance := ps.m2 / float64(ps.n-1)
+	halfWidth := 1.96 * math.Sqrt(variance/float64(ps.n))
+	high := math.Sqrt(math.Min(ps.mean+halfWidth, 1))
+	low := math.Sqrt(math.Min(math.Max(ps.mean-halfWidth, 0), 1))
+	return (high-low)/2 <= threshold
+}
+
+// luminance returns the brightness of a linear color, using Rec. 709 weights.
+func luminance(c color.Color) float64 {
+	return 0.2126*c.X() + 0.7152*c.Y() + 0.0722*c.Z()
+}
+
+// heatColor maps a fraction of the sample budget to a color, running from
+// black through purple, red and yellow to white.
+func heatColor(t float64) color.Color {
+	stops := [...]color.Color{
+		color.NewColor(0, 0, 0),
+		color.NewColor(0.35, 0.05, 0.55),
+		color.NewColor(0.9, 0.15, 0.1),
+		color.NewColor(1, 0.8, 0),
+		color.NewColor(1, 1, 1),
+	}
+	t = math.Max(0, math.Min(t, 1)) * float64(len(stops)-1)
+	k := min(int(t), len(stops)-2)
+	f := t - float64(k)
+	c := stops[k].Scale(1 - f).Add(stops[k+1].Scale(f))
+	// The stops are display colors; undo the gamma that WriteColor applies.
+	return c.Mul(c)
+}
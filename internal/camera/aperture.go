@@ -0,0 +1,100 @@
+package camera
+
+import (
+	"image"
+	"math"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+	"sort"
+)
+
+// aperture samples points on the lens within the shape of the aperture, in
+// coordinates where the aperture fits inside the unit disk.
+type aperture struct {
+	blades   int     // Number of straight diaphragm blades; 0 for a circle
+	rotation float64 // Rotation of the blades in radians
+
+	// Distribution over the pixels of a custom aperture image
+	cdf           []float64
+	width, height int
+}
+
+func newAperture(cfg Config) aperture {
+	a := aperture{
+		blades:   cfg.ApertureBlades,
+		rotation: util.DegreesToRadians(cfg.ApertureRotation),
+	}
+	if cfg.ApertureImage != nil {
+		a.buildImageDistribution(cfg.ApertureImage)
+	}
+	return a
+}
+
+// buildImageDistribution prepares to sample pixels of img in proportion to
+// their brightness.
+func (a *aperture) buildImageDistribution(img image.Image) {
+	bounds := img.Bounds()
+	a.width, a.height = bounds.Dx(), bounds.Dy()
+	a.cdf = make([]float64, a.width*a.height)
+
+	total := 0.0
+	for y := 0; y < a.height; y++ {
+		for x := 0; x < a.width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			total += float64(r+g+b) / (3 * 0xffff)
+			a.cdf[y*a.width+x] = total
+		}
+	}
+
+	if total == 0 {
+		// A black image would block all light; fall back to a circle.
+		a.cdf = nil
+		return
+	}
+	for i := range a.cdf {
+		a.cdf[i] /= total
+	}
+}
+
+// sample returns the point on the aperture for the sample u, v in [0,1).
+func (a aperture) sample(u, v float64) vector.Vec3 {
+	switch {
+	case a.cdf != nil:
+		// Pick a pixel with u, then reuse where u fell within the pixel's
+		// share of the distribution as the horizontal position inside it.
+		i := min(sort.SearchFloat64s(a.cdf, u), len(a.cdf)-1)
+		low := 0.0
+		if i > 0 {
+			low = a.cdf[i-1]
+		}
+		fx := 0.5
+		if a.cdf[i] > low {
+			fx = min((u-low)/(a.cdf[i]-low), 1)
+		}
+		x := float64(i%a.width) + fx
+		y := float64(i/a.width) + v
+		// Fit the image's longer side to the unit disk's diameter.
+		size := float64(max(a.width, a.height))
+		return vector.NewVec3(
+			(2*x-float64(a.width))/size,
+			(float64(a.height)-2*y)/size,
+			0,
+		)
+
+	case a.blades >= 3:
+		// Pick one of the triangles fanning out from the center of the
+		// polygon with u, then map the rest of u and v uniformly into it.
+		scaled := u * float64(a.blades)
+		k := min(int(scaled), a.blades-1)
+		step := 2 * math.Pi / float64(a.blades)
+		angle0 := a.rotation + float64(k)*step
+		v0 := vector.NewVec3(math.Cos(angle0), math.Sin(angle0), 0)
+		v1 := vector.NewVec3(math.Cos(angle0+step), math.Sin(angle0+step), 0)
+
+		r := math.Sqrt(scaled - float64(k))
+		return v0.Scale(r * (1 - v)).Add(v1.Scale(r * v))
+
+	default:
+		return vector.DiskFromSquare(u, v)
+	}
+}
@@ -1,34 +1,104 @@
 package camera
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image"
 	"io"
 	"math"
 	"raytracer/internal/color"
 	"raytracer/internal/core"
 	"raytracer/internal/hittable"
 	"raytracer/internal/interval"
+	"raytracer/internal/light"
 	"raytracer/internal/ray"
+	"raytracer/internal/sampler"
 	"raytracer/internal/util"
 	"raytracer/internal/vector"
 	"runtime"
 	"sync"
+	"time"
 )
 
 // Config holds all camera configuration parameters
 type Config struct {
-	AspectRatio     float64 // Ratio of image width over height
-	ImageWidth      int     // Rendered image width in pixel count
-	SamplesPerPixel int     // Count of random samples for each pixel
-	MaxDepth        int     // Maximum number of ray bounces into scene
-
+	AspectRatio     float64      // Ratio of image width over height
+	ImageWidth      int          // Rendered image width in pixel count
+	SamplesPerPixel int          // Count of random samples for each pixel, the most taken when adaptive
+	MaxDepth        int          // Maximum number of ray bounces into scene
+	Seed            uint64       // Seed for random sampling; equal seeds give identical renders
+	Sampler         sampler.Kind // Strategy for placing the samples of each pixel
+	Filter          Filter       // Reconstruction filter weighting samples into nearby pixels
+	FilterRadius    float64      // Filter radius in pixels; the filter's default if zero
+
+	// Adaptive sampling stops taking samples for a pixel once its brightness
+	// is known to within AdaptiveThreshold, on a 0 to 1 display scale. Pixels
+	// always take at least MinSamples, or 16 if it's zero.
+	AdaptiveThreshold float64
+	MinSamples        int
+	SampleHeatmap     io.Writer // If set, receives a PPM image of the samples each pixel took
+
+	// Progressive rendering takes the samples in passes of PassSamples per
+	// pixel over the whole image, so that Snapshot can be called with the
+	// image so far, at most once every SnapshotInterval. Rendering stops after
+	// the first pass that ends past TimeLimit, if it's set. If PassSamples is
+	// zero, the image is rendered in one pass, unless Snapshot, TimeLimit or
+	// CheckpointPath is set, in which case passes are 4 samples.
+	PassSamples      int
+	Snapshot         func(image *Framebuffer, samples int) error
+	SnapshotInterval time.Duration
+	TimeLimit        time.Duration
+
+	// Checkpointing saves the render's progress to CheckpointPath after
+	// passes, at most once every CheckpointInterval, and whenever rendering
+	// stops early. ResumeFrom continues from a saved checkpoint, giving the
+	// same image as an uninterrupted render of the same scene and settings.
+	CheckpointPath     string
+	CheckpointInterval time.Duration
+	ResumeFrom         string
+
+	TileSize  int       // Width and height of the tiles the image is split into; 32 if zero
+	TileOrder TileOrder // Order in which tiles are rendered
+
+	Progress func(Progress) // If set, called with the render's progress after each tile
+
+	Projection  Projection    // How image positions map to ray directions
 	VerticalFOV float64       // Vertical view angle (field of view)
+	ViewWidth   float64       // Width of the view in scene units, for orthographic projection
+	FisheyeFOV  float64       // View angle across the image circle, for fisheye projections
 	LookFrom    vector.Point3 // Point camera is looking from
 	LookAt      vector.Point3 // Point camera is looking at
 	VUp         vector.Vec3   // Camera-relative "up" direction
 
 	DefocusAngle float64 // Variation angle of rays through each pixel
 	FocusDist    float64 // Distance from camera lookFrom point to plane of perfect focus
+
+	// Physical exposure settings. When ISO, ShutterSpeed and FStop are all set,
+	// they scale the image brightness, calibrated so that ISO 100 at f/16 and
+	// 1/100s exposes a sunlit scene (the "sunny 16" rule). FStop together with
+	// FocalLength sets the aperture in place of DefocusAngle, with scene units
+	// taken to be meters.
+	ISO          float64 // Film or sensor sensitivity
+	ShutterSpeed float64 // Exposure time in seconds, which is also the motion blur duration
+	FStop        float64 // Ratio of focal length to aperture diameter
+	FocalLength  float64 // Lens focal length in millimeters
+
+	ShiftX float64 // Horizontal lens shift as a fraction of the view width
+	ShiftY float64 // Vertical lens shift as a fraction of the view height
+	TiltX  float64 // Focal plane tilt about the camera's horizontal axis, in degrees
+	TiltY  float64 // Focal plane tilt about the camera's vertical axis, in degrees
+
+	ApertureBlades   int         // Number of diaphragm blades shaping the bokeh; 0 for circular
+	ApertureRotation float64     // Rotation of the aperture blades in degrees
+	ApertureImage    image.Image // Grayscale aperture shape, overriding the blades if set
+	CatsEye          float64     // Strength of cat's-eye vignetting towards the image edges
+	Lens             *LensSystem // Lens prescription to trace rays through, replacing the thin lens
+
+	StereoMode          StereoMode  // Mono, or the layout of a stereo pair
+	InterocularDistance float64     // Distance between the eyes for stereo rendering
+	Convergence         Convergence // How the eyes' views are converged
+	ConvergenceDist     float64     // Distance at which the eyes converge; FocusDist if zero
 }
 
 // DefaultConfig returns a Config with reasonable default values
@@ -49,19 +119,28 @@ func DefaultConfig() Config {
 
 // Camera represents a virtual camera for ray tracing
 type Camera struct {
-	config           Config
-	imageHeight      int           // Rendered image height
-	pixelSampleScale float64       // Color scale factor for a sum of pixel samples
-	center           vector.Point3 // Camera center
-	pixel00Location  vector.Point3 // Offset of pixel 0,0
-	pixelDeltaU      vector.Vec3   // Offset to pixel to the right
-	pixelDeltaV      vector.Vec3   // Offset to pixel below
-	basis            struct {
+	config          Config
+	imageHeight     int           // Rendered image height
+	exposureScale   float64       // Brightness scale from the exposure settings
+	center          vector.Point3 // Camera center
+	pixel00Location vector.Point3 // Offset of pixel 0,0
+	pixelDeltaU     vector.Vec3   // Offset to pixel to the right
+	pixelDeltaV     vector.Vec3   // Offset to pixel below
+	basis           struct {
 		// Camera frame basis vectors
 		u, v, w vector.Vec3
 	}
-	defocusDiskU vector.Vec3 // Defocus disk horiztonal radius
-	defocusDiskV vector.Vec3 // Defocus disk vertical radius
+	defocusRadius float64     // Radius of the defocus disk, zero for a pinhole
+	defocusDiskU  vector.Vec3 // Defocus disk horiztonal radius
+	defocusDiskV  vector.Vec3 // Defocus disk vertical radius
+	aperture      aperture    // Shape of the defocus disk
+	focusNormal   vector.Vec3 // Normal of the plane of focus, tilted away from the view axis
+	lens          *LensSystem // Lens system focused at FocusDist, if any
+
+	eyes          []*Camera // Left and right eye cameras, for stereo rendering
+	viewportShift float64   // Horizontal viewport offset for off-axis stereo
+	eyeOffset     float64   // Ray origin offset for omnidirectional stereo
+	streamOffset  uint64    // Offset of this camera's random streams, so stereo eyes differ
 }
 
 // New creates a new Camera with the given configuration
@@ -71,14 +150,22 @@ func New(cfg Config) (*Camera, error) {
 	}
 
 	cam := &Camera{
-		config:           cfg,
-		pixelSampleScale: 1.0 / float64(cfg.SamplesPerPixel),
+		config:        cfg,
+		exposureScale: exposure(cfg),
 	}
 
 	if err := cam.initialize(); err != nil {
 		return nil, fmt.Errorf("failed to initialize camera: %w", err)
 	}
 
+	if cfg.StereoMode != Mono {
+		eyes, err := newEyes(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize stereo eyes: %w", err)
+		}
+		cam.eyes = eyes[:]
+	}
+
 	return cam, nil
 }
 
@@ -95,12 +182,58 @@ func validateConfig(cfg Config) error {
 	if cfg.MaxDepth <= 0 {
 		return fmt.Errorf("max depth must be positive, got %v", cfg.MaxDepth)
 	}
+	if cfg.AdaptiveThreshold < 0 {
+		return fmt.Errorf("adaptive threshold must not be negative, got %v", cfg.AdaptiveThreshold)
+	}
+	if cfg.MinSamples < 0 || cfg.MinSamples > cfg.SamplesPerPixel {
+		return fmt.Errorf("min samples must be between 0 and samples per pixel, got %v", cfg.MinSamples)
+	}
+	if cfg.FilterRadius < 0 {
+		return fmt.Errorf("filter radius must not be negative, got %v", cfg.FilterRadius)
+	}
+	if cfg.TileSize < 0 {
+		return fmt.Errorf("tile size must not be negative, got %v", cfg.TileSize)
+	}
+	if cfg.PassSamples < 0 {
+		return fmt.Errorf("pass samples must not be negative, got %v", cfg.PassSamples)
+	}
+	if _, err := sampler.New(cfg.Sampler, cfg.SamplesPerPixel, cfg.Seed); err != nil {
+		return err
+	}
+	if cfg.Projection == Orthographic && cfg.ViewWidth <= 0 {
+		return fmt.Errorf("view width must be positive for orthographic projection, got %v", cfg.ViewWidth)
+	}
+	if cfg.Projection.isFisheye() && (cfg.FisheyeFOV <= 0 || cfg.FisheyeFOV > 360) {
+		return fmt.Errorf("fisheye field of view must be in (0, 360], got %v", cfg.FisheyeFOV)
+	}
+	if cfg.Projection == CubeMap && cfg.ImageWidth%3 != 0 {
+		return fmt.Errorf("image width must be a multiple of 3 for a cube map, got %v", cfg.ImageWidth)
+	}
+	if cfg.ISO < 0 || cfg.ShutterSpeed < 0 || cfg.FStop < 0 || cfg.FocalLength < 0 {
+		return fmt.Errorf("exposure settings must not be negative")
+	}
+	if math.Abs(cfg.TiltX) >= 90 || math.Abs(cfg.TiltY) >= 90 {
+		return fmt.Errorf("focal plane tilt must be less than 90 degrees, got %v, %v", cfg.TiltX, cfg.TiltY)
+	}
+	if cfg.CatsEye < 0 {
+		return fmt.Errorf("cat's eye strength must not be negative, got %v", cfg.CatsEye)
+	}
+	if cfg.Lens != nil && cfg.Projection != Perspective {
+		return fmt.Errorf("a lens system can only be used with perspective projection")
+	}
+	if cfg.StereoMode != Mono && cfg.InterocularDistance <= 0 {
+		return fmt.Errorf("interocular distance must be positive for stereo rendering, got %v", cfg.InterocularDistance)
+	}
 	return nil
 }
 
 func (c *Camera) initialize() error {
-	// Calculate image height maintaining minimum of 1
+	// Calculate image height maintaining minimum of 1. Cube maps are laid out
+	// as a 3x2 grid of square faces, ignoring AspectRatio.
 	c.imageHeight = int(float64(c.config.ImageWidth) / c.config.AspectRatio)
+	if c.config.Projection == CubeMap {
+		c.imageHeight = 2 * (c.config.ImageWidth / 3)
+	}
 	if c.imageHeight < 1 {
 		c.imageHeight = 1
 	}
@@ -108,10 +241,16 @@ func (c *Camera) initialize() error {
 	c.center = c.config.LookFrom
 
 	// Calculate viewport dimensions
-	theta := util.DegreesToRadians(c.config.VerticalFOV)
-	h := math.Tan(theta / 2)
-	viewportHeight := 2.0 * h * c.config.FocusDist
-	viewportWidth := viewportHeight * (float64(c.config.ImageWidth) / float64(c.imageHeight))
+	var viewportWidth, viewportHeight float64
+	if c.config.Projection == Orthographic {
+		viewportWidth = c.config.ViewWidth
+		viewportHeight = viewportWidth * (float64(c.imageHeight) / float64(c.config.ImageWidth))
+	} else {
+		theta := util.DegreesToRadians(c.config.VerticalFOV)
+		h := math.Tan(theta / 2)
+		viewportHeight = 2.0 * h * c.config.FocusDist
+		viewportWidth = viewportHeight * (float64(c.config.ImageWidth) / float64(c.imageHeight))
+	}
 
 	// Calculate camera basis vectors
 	c.basis.w = c.config.LookFrom.Sub(c.config.LookAt).Unit()
@@ -128,152 +267,422 @@ func (c *Camera) initialize() error {
 	c.pixelDeltaV = viewportV.Div(float64(c.imageHeight))
 
 	// Calculate upper left pixel location
+	// Lens shift slides the viewport within its plane, keeping the view
+	// direction and so keeping vertical lines parallel.
+	shiftU := c.viewportShift + c.config.ShiftX*viewportWidth
+	shiftV := c.config.ShiftY * viewportHeight
 	viewportUpperLeft := c.center.
 		Sub(c.basis.w.Scale(c.config.FocusDist)).
 		Sub(viewportU.Div(2)).
-		Sub(viewportV.Div(2))
+		Sub(viewportV.Div(2)).
+		Add(c.basis.u.Scale(shiftU)).
+		Add(c.basis.v.Scale(shiftV))
 	c.pixel00Location = viewportUpperLeft.Add(c.pixelDeltaU.Add(c.pixelDeltaV).Scale(0.5))
 
-	// Calculate the camera defocus disk basis vectors.
-	defocusRadius := c.config.FocusDist * math.Tan(util.DegreesToRadians(c.config.DefocusAngle/2))
-	c.defocusDiskU = c.basis.u.Scale(defocusRadius)
-	c.defocusDiskV = c.basis.v.Scale(defocusRadius)
+	// Calculate the camera defocus disk basis vectors. A physical aperture is
+	// the focal length divided by the f-number, converted from millimeters.
+	c.defocusRadius = c.config.FocusDist * math.Tan(util.DegreesToRadians(c.config.DefocusAngle/2))
+	if c.config.FStop > 0 && c.config.FocalLength > 0 {
+		c.defocusRadius = c.config.FocalLength / c.config.FStop / 2 / 1000
+	}
+	c.defocusDiskU = c.basis.u.Scale(c.defocusRadius)
+	c.defocusDiskV = c.basis.v.Scale(c.defocusRadius)
+	c.aperture = newAperture(c.config)
+
+	// Tilt the plane of focus by rotating its normal about the camera axes.
+	c.focusNormal = c.basis.w
+	c.focusNormal = rotateAbout(c.focusNormal, c.basis.u, util.DegreesToRadians(c.config.TiltX))
+	c.focusNormal = rotateAbout(c.focusNormal, c.basis.v, util.DegreesToRadians(c.config.TiltY))
+
+	if c.config.Lens != nil {
+		lens, err := c.config.Lens.focused(c.config.FocusDist)
+		if err != nil {
+			return fmt.Errorf("failed to focus lens: %w", err)
+		}
+		c.lens = lens
+	}
 
 	return nil
 }
 
-// Scanline represents a single row of pixels
-type Scanline struct {
-	row    int
-	pixels []color.Color
+// scene holds everything a ray can interact with during a render
+type scene struct {
+	world  hittable.Hittable
+	lights []light.Light
+}
+
+// Render renders the scene to the provided writer using parallel processing.
+// Lights that aren't part of the world's geometry are sampled with shadow rays.
+// In stereo mode both eyes are rendered and written as a single image.
+//
+// If ctx is canceled or its deadline passes, the workers stop and the image
+// rendered so far is still written, but Render returns an error wrapping
+// ctx.Err(). It returns statistics of the work done even when it fails, and
+// summarizes them to log once the image is written.
+func (c *Camera) Render(ctx context.Context, out io.Writer, log io.Writer, world hittable.Hittable, lights ...light.Light) (Stats, error) {
+	s := scene{world, lights}
+
+	// Stereo renders a film for each eye.
+	views := []*Camera{c}
+	if c.eyes != nil {
+		views = c.eyes
+	}
+	films := make([]*film, len(views))
+	for k, view := range views {
+		films[k] = newFilm(view.config.ImageWidth, view.imageHeight, newFilter(c.config.Filter, c.config.FilterRadius))
+	}
+
+	// Carry on from the pixel that's furthest behind.
+	taken := 0
+	if c.config.ResumeFrom != "" {
+		if err := c.loadCheckpoint(c.config.ResumeFrom, films); err != nil {
+			return Stats{}, fmt.Errorf("failed to resume: %w", err)
+		}
+		taken = c.config.SamplesPerPixel
+		for _, f := range films {
+			taken = min(taken, f.minSamples(c.config.SamplesPerPixel))
+		}
+		fmt.Fprintf(log, "Resuming from %d samples per pixel\n", taken)
+	}
+
+	passSamples := c.passSamples()
+	passes := (c.config.SamplesPerPixel - taken + passSamples - 1) / passSamples
+	tracker := newProgressTracker(passes*len(views)*c.tileCount(), c.config.Progress)
+
+	start := time.Now()
+	lastSnapshot := start
+	lastCheckpoint := start
+	var canceled error
+	stoppedEarly := false
+	for taken < c.config.SamplesPerPixel {
+		taken = min(taken+passSamples, c.config.SamplesPerPixel)
+		for k, view := range views {
+			if err := view.renderPass(ctx, s, films[k], taken, tracker); err != nil {
+				canceled = fmt.Errorf("render canceled: %w", err)
+				break
+			}
+		}
+		if canceled != nil {
+			stoppedEarly = true
+			break
+		}
+		if taken == c.config.SamplesPerPixel {
+			break
+		}
+
+		if c.config.TimeLimit > 0 && time.Since(start) >= c.config.TimeLimit {
+			fmt.Fprintf(log, "\nTime limit reached after %d samples per pixel", taken)
+			stoppedEarly = true
+			break
+		}
+		if c.config.CheckpointPath != "" && time.Since(lastCheckpoint) >= c.config.CheckpointInterval {
+			// A failed checkpoint only risks the progress since the last one,
+			// so keep rendering rather than lose the film.
+			if err := c.saveCheckpoint(c.config.CheckpointPath, films); err != nil {
+				fmt.Fprintf(log, "\nFailed to save checkpoint: %v\n", err)
+			}
+			lastCheckpoint = time.Now()
+		}
+		if c.config.Snapshot != nil && time.Since(lastSnapshot) >= c.config.SnapshotInterval {
+			if err := c.config.Snapshot(c.develop(films, (*film).image), taken); err != nil {
+				return tracker.total(), fmt.Errorf("failed to take snapshot: %w", err)
+			}
+			lastSnapshot = time.Now()
+		}
+	}
+
+	// The image is still written if the final checkpoint fails.
+	var checkpointErr error
+	if stoppedEarly && c.config.CheckpointPath != "" {
+		checkpointErr = c.saveCheckpoint(c.config.CheckpointPath, films)
+	}
+
+	stats := tracker.total()
+
+	if err := c.develop(films, (*film).image).WritePPM(out); err != nil {
+		return stats, err
+	}
+	if c.config.SampleHeatmap != nil {
+		heatmap := c.develop(films, func(f *film) *Framebuffer {
+			return f.heatmap(c.config.SamplesPerPixel)
+		})
+		if err := heatmap.WritePPM(c.config.SampleHeatmap); err != nil {
+			return stats, fmt.Errorf("failed to write sample heatmap: %w", err)
+		}
+	}
+
+	if canceled != nil {
+		fmt.Fprintln(log, "\nCanceled.")
+	} else {
+		fmt.Fprintln(log, "\nDone.")
+	}
+	stats.WriteSummary(log)
+	return stats, errors.Join(canceled, checkpointErr)
 }
 
-// Render renders the scene to the provided writer using parallel processing
-func (c *Camera) Render(out io.Writer, log io.Writer, world hittable.Hittable) error {
-	// Write header
-	if _, err := fmt.Fprintf(out, "P3\n%d %d\n255\n", c.config.ImageWidth, c.imageHeight); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
+// develop converts the films to a single image, joining stereo eyes.
+func (c *Camera) develop(films []*film, convert func(*film) *Framebuffer) *Framebuffer {
+	if len(films) == 2 {
+		return composite(c.config.StereoMode, convert(films[0]), convert(films[1]))
 	}
+	return convert(films[0])
+}
+
+// renderPass adds samples to every pixel of the film using a pool of workers,
+// until each has taken end samples or converged. The image is split into
+// tiles, which the workers share out through a work-stealing scheduler. If ctx
+// is done, the workers stop early and renderPass returns ctx.Err().
+func (c *Camera) renderPass(ctx context.Context, s scene, f *film, end int, tracker *progressTracker) error {
+	ts := tiles(c.config.ImageWidth, c.imageHeight, c.tileSize(), c.config.TileOrder)
 
-	// Set up worker pool size and channels
+	// Set up worker pool size and scheduler
 	numWorkers := runtime.GOMAXPROCS(0)
-	jobs := make(chan int, numWorkers)
-	results := make(chan Scanline, numWorkers)
+	sched := newScheduler(ts, numWorkers)
+	results := make(chan tileResult, numWorkers)
 
 	// Create a WaitGroup for workers
 	var wg sync.WaitGroup
 
 	// Start the worker pool
-	for i := 0; i < numWorkers; i++ {
+	for worker := 0; worker < numWorkers; worker++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			smp := c.newSampler()
 
-			// Process jobs until channel is closed
-			for row := range jobs {
-				// Create scanline pixels
-				pixels := make([]color.Color, c.config.ImageWidth)
-				for i := 0; i < c.config.ImageWidth; i++ {
-					pixels[i] = c.samplePixel(i, row, world).Scale(c.pixelSampleScale)
+			// Process tiles until there are none left
+			for {
+				t, ok := sched.next(worker)
+				if !ok {
+					return
+				}
+				var st Stats
+				for j := t.y0; j < t.y1; j++ {
+					if ctx.Err() != nil {
+						// Still count the work done on the tile so far.
+						results <- tileResult{st, false}
+						return
+					}
+					for i := t.x0; i < t.x1; i++ {
+						c.samplePixel(i, j, s, smp, f, end, &st)
+					}
 				}
 
-				// Send completed scanline
-				results <- Scanline{row: row, pixels: pixels}
+				// Report the completed tile
+				results <- tileResult{st, true}
 			}
 		}()
 	}
 
-	// Start job distribution in a separate goroutine
+	// Close the results channel once the workers are done
 	go func() {
-		// Send all row numbers as jobs
-		for j := 0; j < c.imageHeight; j++ {
-			jobs <- j
-		}
-
-		// Close jobs channel to signal no more work
-		close(jobs)
-
-		// Wait for all workers to finish
 		wg.Wait()
-
-		// Close results channel
 		close(results)
 	}()
 
-	// Collect and store results
-	buffer := make([][]color.Color, c.imageHeight)
-	total := c.imageHeight
+	// Wait for the results, until the workers finish or give up
+	for result := range results {
+		tracker.add(result)
+	}
+	return ctx.Err()
+}
 
-	for i := total; i > 0; i-- {
-		scanline := <-results
-		buffer[scanline.row] = scanline.pixels
-		fmt.Fprintf(log, "\rScanlines remaining: %d", i-1)
+// defaultPassSamples is the samples per pixel in each pass when PassSamples is
+// zero but the render still needs passes to end at.
+const defaultPassSamples = 4
+
+// passSamples returns the samples per pixel taken in each pass over the image.
+// Snapshots, checkpoints and the time limit are only handled between passes, so
+// they get short passes by default rather than one covering the whole render.
+func (c *Camera) passSamples() int {
+	switch {
+	case c.config.PassSamples > 0:
+		return c.config.PassSamples
+	case c.config.Snapshot != nil || c.config.TimeLimit > 0 || c.config.CheckpointPath != "":
+		return min(defaultPassSamples, c.config.SamplesPerPixel)
+	default:
+		return c.config.SamplesPerPixel
 	}
+}
 
-	// Write the results in order
-	for j := 0; j < c.imageHeight; j++ {
-		for _, pixel := range buffer[j] {
-			if err := color.WriteColor(out, pixel); err != nil {
-				return fmt.Errorf("failed to write pixel: %w", err)
-			}
-		}
+// tileSize returns the width and height of the tiles the image is split into.
+func (c *Camera) tileSize() int {
+	if c.config.TileSize == 0 {
+		return defaultTileSize
 	}
+	return c.config.TileSize
+}
 
-	fmt.Fprintln(log, "\nDone.")
-	return nil
+// tileCount returns the number of tiles in one pass over the image.
+func (c *Camera) tileCount() int {
+	size := c.tileSize()
+	return ((c.config.ImageWidth + size - 1) / size) * ((c.imageHeight + size - 1) / size)
+}
+
+// newSampler returns a sampler for one worker. The stream offset is folded
+// into the seed so that stereo eyes see different noise.
+func (c *Camera) newSampler() sampler.Sampler {
+	// The sampler kind was checked by validateConfig.
+	smp, _ := sampler.New(c.config.Sampler, c.config.SamplesPerPixel, c.config.Seed+c.streamOffset)
+	return smp
 }
 
-func (c *Camera) renderScanline(j int, out io.Writer, world hittable.Hittable) error {
-	for i := 0; i < c.config.ImageWidth; i++ {
-		pixelColor := c.samplePixel(i, j, world)
-		if err := color.WriteColor(out, pixelColor.Scale(c.pixelSampleScale)); err != nil {
-			return err
+// samplePixel adds samples of pixel i, j to the film until it has taken end
+// samples or converged. The sampler is restarted for every sample of every
+// pixel, so the result doesn't depend on which worker renders it, in what
+// order, or over how many passes.
+func (c *Camera) samplePixel(i, j int, s scene, smp sampler.Sampler, f *film, end int, st *Stats) {
+	stats := f.at(i, j)
+	minSamples := c.config.MinSamples
+	if minSamples == 0 {
+		minSamples = min(defaultMinSamples, c.config.SamplesPerPixel)
+	}
+
+	for !stats.done && stats.n < end {
+		smp.StartPixelSample(i, j, stats.n)
+		cs := newCameraSample(smp)
+		sample := c.sampleRay(i, j, cs, s, smp, st).Scale(c.exposureScale)
+		st.Samples++
+		stats.add(sample)
+		f.addSample(i, j, cs.pixelX, cs.pixelY, sample)
+
+		if c.config.AdaptiveThreshold > 0 && stats.n >= minSamples && stats.converged(c.config.AdaptiveThreshold) {
+			stats.done = true
 		}
 	}
-	return nil
 }
 
-func (c *Camera) samplePixel(i, j int, world hittable.Hittable) color.Color {
-	pixelColor := color.NewColor(0, 0, 0)
-	for sample := 0; sample < c.config.SamplesPerPixel; sample++ {
-		r := c.getRay(i, j)
-		pixelColor = pixelColor.Add(c.traceRay(r, c.config.MaxDepth, world))
+// sampleRay traces the camera ray for sample cs of pixel i, j, returning black
+// if the camera can't see through that sample.
+func (c *Camera) sampleRay(i, j int, cs cameraSample, s scene, smp sampler.Sampler, st *Stats) color.Color {
+	r, ok := c.getRay(i, j, cs)
+	if !ok {
+		return color.NewColor(0, 0, 0)
 	}
-	return pixelColor
+	// Spread samples over the time the shutter is open.
+	r = ray.NewRayWithTime(r.Origin(), r.Direction(), cs.time*c.config.ShutterSpeed)
+	return c.traceRay(r, c.config.MaxDepth, s, smp, st)
+}
+
+// cameraSample holds the sample values that place a camera ray.
+type cameraSample struct {
+	pixelX, pixelY float64 // Offset within the pixel, in [-0.5,0.5]
+	lensU, lensV   float64 // Position on the lens, in [0,1)
+	time           float64 // Fraction of the shutter interval
 }
 
-func (c *Camera) getRay(i, j int) ray.Ray {
+// newCameraSample draws a camera sample. The lens values are drawn even when
+// there's no lens, so the dimensions used for shading stay the same.
+func newCameraSample(smp sampler.Sampler) cameraSample {
+	var cs cameraSample
+	cs.pixelX, cs.pixelY = smp.Get2D()
+	cs.pixelX -= 0.5
+	cs.pixelY -= 0.5
+	cs.lensU, cs.lensV = smp.Get2D()
+	cs.time = smp.Get1D()
+	return cs
+}
+
+// getRay returns a camera ray through a random point in pixel i, j. It reports
+// false for pixels that the projection doesn't cover, such as the corners of a
+// fisheye image.
+func (c *Camera) getRay(i, j int, cs cameraSample) (ray.Ray, bool) {
+	// Add random offset within pixel for anti-aliasing
+	offset := vector.NewVec3(cs.pixelX, cs.pixelY, 0)
+
+	if c.config.Projection.isPanoramic() {
+		x := float64(i) + 0.5 + offset.X()
+		y := float64(j) + 0.5 + offset.Y()
+		direction, ok := c.panoramicDirection(x, y)
+		origin := c.center
+		if c.eyeOffset != 0 {
+			// Offset towards the eye's side of the view direction, fading to
+			// nothing straight up and down to avoid distortion at the poles.
+			origin = origin.Add(vector.Cross(direction.Unit(), c.basis.v).Scale(c.eyeOffset))
+		}
+		return ray.NewRay(origin, direction), ok
+	}
+
+	if c.config.Lens != nil {
+		x := (float64(i) + 0.5 + offset.X()) / float64(c.config.ImageWidth)
+		y := (float64(j) + 0.5 + offset.Y()) / float64(c.imageHeight)
+		return c.lensRay(x, y, cs.lensU, cs.lensV)
+	}
+
 	pixelCenter := c.pixel00Location.
 		Add(c.pixelDeltaU.Scale(float64(i))).
 		Add(c.pixelDeltaV.Scale(float64(j)))
 
-	// Add random offset within pixel for anti-aliasing
-	offset := samplePixelSquare()
 	pixelSample := pixelCenter.
 		Add(c.pixelDeltaU.Scale(offset.X())).
 		Add(c.pixelDeltaV.Scale(offset.Y()))
 
+	// Perspective rays all start at the camera center. Orthographic rays start
+	// on the plane through the center, directly behind their pixel sample, so
+	// that they all run parallel.
 	rayOrigin := c.center
-	if c.config.DefocusAngle > 0 {
-		rayOrigin = c.defocusDiskSample()
+	if c.config.Projection == Orthographic {
+		rayOrigin = pixelSample.Add(c.basis.w.Scale(c.config.FocusDist))
+	}
+	if c.defocusRadius > 0 {
+		focusPoint := c.focusPoint(rayOrigin, pixelSample)
+		var ok bool
+		rayOrigin, ok = c.defocusDiskSample(rayOrigin, c.filmPosition(i, j), cs.lensU, cs.lensV)
+		if !ok {
+			return ray.Ray{}, false
+		}
+		pixelSample = focusPoint
 	}
 	rayDirection := pixelSample.Sub(rayOrigin)
-	return ray.NewRay(rayOrigin, rayDirection)
+	return ray.NewRay(rayOrigin, rayDirection), true
 }
 
-func (c *Camera) traceRay(r ray.Ray, depth int, world hittable.Hittable) color.Color {
+// focusPoint returns where the pinhole ray from origin through pixelSample meets
+// the plane of focus. Without tilt this is pixelSample itself, since the
+// viewport lies in the plane of focus.
+func (c *Camera) focusPoint(origin, pixelSample vector.Point3) vector.Point3 {
+	if c.config.TiltX == 0 && c.config.TiltY == 0 {
+		return pixelSample
+	}
+
+	direction := pixelSample.Sub(origin)
+	denom := vector.Dot(c.focusNormal, direction)
+	if math.Abs(denom) < 1e-8 {
+		return pixelSample
+	}
+	planePoint := c.center.Sub(c.basis.w.Scale(c.config.FocusDist))
+	t := vector.Dot(c.focusNormal, planePoint.Sub(origin)) / denom
+	if t <= 0 {
+		return pixelSample
+	}
+	return origin.Add(direction.Scale(t))
+}
+
+func (c *Camera) traceRay(r ray.Ray, depth int, s scene, rng util.RNG, st *Stats) color.Color {
 	if depth <= 0 {
+		st.MaxDepthTerminations++
 		return color.NewColor(0, 0, 0)
 	}
+	if depth == c.config.MaxDepth {
+		st.PrimaryRays++
+	} else {
+		st.SecondaryRays++
+	}
 
 	var rec core.HitRecord
-	if world.Hit(r, interval.NewInterval(0.001, math.Inf(1)), &rec) {
+	if s.hit(r, interval.NewInterval(0.001, math.Inf(1)), &rec, st) {
+		emitted := color.NewColor(0, 0, 0)
+		if emitter, ok := rec.Material().(core.Emitter); ok {
+			emitted = emitter.Emitted(&rec)
+		}
+		direct := c.sampleLights(r, &rec, s, rng, st)
+
 		var scattered ray.Ray
 		var attenuation color.Color
-		if rec.Material().Scatter(r, &rec, &attenuation, &scattered) {
-			return attenuation.Mul(c.traceRay(scattered, depth-1, world))
+		if rec.Material().Scatter(r, &rec, &attenuation, &scattered, rng) {
+			return emitted.Add(direct).Add(attenuation.Mul(c.traceRay(scattered, depth-1, s, rng, st)))
 		}
-		return color.NewColor(0, 0, 0)
+		return emitted.Add(direct)
 	}
 
 	// Render sky gradient
@@ -282,19 +691,82 @@ func (c *Camera) traceRay(r ray.Ray, depth int, world hittable.Hittable) color.C
 	return color.NewColor(1.0, 1.0, 1.0).Scale(1.0 - t).Add(color.NewColor(0.5, 0.7, 1.0).Scale(t))
 }
 
-func (c Camera) defocusDiskSample() vector.Point3 {
-	// Returns a random point in the camera defocus disk.
-	p := vector.RandomInUnitDisk()
-	return c.center.
+// sampleLights returns the light arriving directly from the scene's lights and
+// reflected along r, casting a shadow ray towards each light.
+func (c *Camera) sampleLights(r ray.Ray, rec *core.HitRecord, s scene, rng util.RNG, st *Stats) color.Color {
+	direct := color.NewColor(0, 0, 0)
+	bsdf, ok := rec.Material().(core.BSDF)
+	if !ok {
+		return direct
+	}
+
+	for _, l := range s.lights {
+		wi, dist, li := l.Sample(rec.Point(), rng)
+		if li.NearZero() {
+			continue
+		}
+
+		f := bsdf.Eval(r, rec, wi)
+		if f.NearZero() {
+			continue
+		}
+
+		var shadowRec core.HitRecord
+		shadowRay := ray.NewRayWithTime(rec.Point(), wi, r.Time())
+		st.ShadowRays++
+		if s.hit(shadowRay, interval.NewInterval(0.001, dist*(1-1e-6)), &shadowRec, st) {
+			continue
+		}
+
+		direct = direct.Add(f.Mul(li))
+	}
+	return direct
+}
+
+// defocusDiskSample returns the point in the camera defocus disk around center
+// for the lens sample u, v, for a pixel at the given film position. It reports
+// false if the lens sample is blocked by cat's-eye vignetting.
+func (c Camera) defocusDiskSample(center vector.Point3, film vector.Vec3, u, v float64) (vector.Point3, bool) {
+	p := c.aperture.sample(u, v)
+
+	// Light reaching the edges of the image is clipped by the lens barrel,
+	// which is modeled as a second disk sliding off-center.
+	if c.config.CatsEye > 0 && p.Sub(film.Scale(c.config.CatsEye)).LengthSquared() > 1 {
+		return vector.Point3{}, false
+	}
+
+	return center.
 		Add(c.defocusDiskU.Scale(p.X())).
-		Add(c.defocusDiskV.Scale(p.Y()))
+		Add(c.defocusDiskV.Scale(p.Y())), true
 }
 
-// samplePixelSquare returns a random point in the [-0.5,0.5] square
-func samplePixelSquare() vector.Vec3 {
+// filmPosition returns pixel i, j's offset from the image center, scaled so the
+// corners are at distance 1.
+func (c Camera) filmPosition(i, j int) vector.Vec3 {
+	halfWidth := float64(c.config.ImageWidth) / 2
+	halfHeight := float64(c.imageHeight) / 2
+	halfDiagonal := math.Sqrt(halfWidth*halfWidth + halfHeight*halfHeight)
 	return vector.NewVec3(
-		util.RandomFloat()-0.5,
-		util.RandomFloat()-0.5,
+		(float64(i)+0.5-halfWidth)/halfDiagonal,
+		(halfHeight-float64(j)-0.5)/halfDiagonal,
 		0,
 	)
 }
+
+// exposure returns the brightness scale for the physical exposure settings,
+// or 1 if they aren't all set.
+func exposure(cfg Config) float64 {
+	if cfg.ISO <= 0 || cfg.ShutterSpeed <= 0 || cfg.FStop <= 0 {
+		return 1
+	}
+	return (cfg.ISO / 100) * (cfg.ShutterSpeed * 100) * (16 / cfg.FStop) * (16 / cfg.FStop)
+}
+
+// rotateAbout rotates v by angle radians about the unit vector axis, using
+// Rodrigues' rotation formula.
+func rotateAbout(v, axis vector.Vec3, angle float64) vector.Vec3 {
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	return v.Scale(cos).
+		Add(vector.Cross(axis, v).Scale(sin)).
+		Add(axis.Scale(vector.Dot(axis, v) * (1 - cos)))
+}
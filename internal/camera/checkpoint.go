@@ -0,0 +1,171 @@
+package camera
+
+import (
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+)
+
+// checkpointVersion changes whenever the checkpoint format does.
+const checkpointVersion = 1
+
+// checkpoint is the saved state of a render: the films of each view. Since
+// every pixel sample is determined by the seed, the pixel and the sample's
+// index, the number of samples each pixel has taken is all the sampler state
+// needed to carry on exactly where the render stopped.
+type checkpoint struct {
+	Version     int
+	Fingerprint string // Settings that must match for the render to continue
+	Films       []filmState
+}
+
+type filmState struct {
+	Width, Height int
+	Pixels        []pixelState
+	Splats        []int64 // Red, green, blue and weight sums of each pixel
+}
+
+type pixelState struct {
+	N        int
+	Mean, M2 float64
+	Done     bool
+}
+
+// fingerprint describes the settings that determine each pixel's samples and
+// how they're accumulated. A checkpoint can only be resumed with the same
+// fingerprint; the scene itself can't be checked, and must also be the same.
+func (c *Camera) fingerprint() string {
+	cfg := c.config
+	return fmt.Sprintf("%dx%d spp=%d seed=%d sampler=%d filter=%d/%v adaptive=%v/%d stereo=%d exposure=%v camera=%016x",
+		cfg.ImageWidth, c.imageHeight, cfg.SamplesPerPixel, cfg.Seed, cfg.Sampler,
+		cfg.Filter, cfg.FilterRadius, cfg.AdaptiveThreshold, cfg.MinSamples, cfg.StereoMode, c.exposureScale,
+		c.raysHash())
+}
+
+// raysHash hashes every camera setting that changes the rays traced for a
+// sample, so that a checkpoint can't be resumed with the camera moved,
+// refocused or given a different lens.
+func (c *Camera) raysHash() uint64 {
+	cfg := c.config
+	var lens LensSystem
+	if c.lens != nil {
+		lens = *c.lens
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v", []any{
+		cfg.MaxDepth,
+		cfg.Projection, cfg.VerticalFOV, cfg.ViewWidth, cfg.FisheyeFOV,
+		cfg.LookFrom, cfg.LookAt, cfg.VUp,
+		cfg.DefocusAngle, cfg.FocusDist,
+		cfg.ShutterSpeed, cfg.FStop, cfg.FocalLength,
+		cfg.ShiftX, cfg.ShiftY, cfg.TiltX, cfg.TiltY,
+		c.aperture, cfg.CatsEye, c.lens != nil, lens,
+		cfg.InterocularDistance, cfg.Convergence, cfg.ConvergenceDist,
+	})
+	return h.Sum64()
+}
+
+// saveCheckpoint writes the films to path. It writes a temporary file first
+// and renames it, so a crash while saving leaves the previous checkpoint.
+func (c *Camera) saveCheckpoint(path string, films []*film) error {
+	cp := checkpoint{Version: checkpointVersion, Fingerprint: c.fingerprint()}
+	for _, f := range films {
+		cp.Films = append(cp.Films, f.state())
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(cp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// loadCheckpoint restores the films from the checkpoint at path.
+func (c *Camera) loadCheckpoint(path string, films []*film) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	defer file.Close()
+
+	var cp checkpoint
+	if err := gob.NewDecoder(file).Decode(&cp); err != nil {
+		return fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	if cp.Version != checkpointVersion {
+		return fmt.Errorf("unsupported checkpoint version %d", cp.Version)
+	}
+	if cp.Fingerprint != c.fingerprint() {
+		return fmt.Errorf("checkpoint settings %q don't match the camera's %q", cp.Fingerprint, c.fingerprint())
+	}
+	if len(cp.Films) != len(films) {
+		return fmt.Errorf("checkpoint has %d views, expected %d", len(cp.Films), len(films))
+	}
+	for k, f := range films {
+		if err := f.restore(cp.Films[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *film) state() filmState {
+	s := filmState{
+		Width:  f.width,
+		Height: f.height,
+		Pixels: make([]pixelState, len(f.pixels)),
+		Splats: make([]int64, 0, 4*len(f.splats)),
+	}
+	for k, ps := range f.pixels {
+		s.Pixels[k] = pixelState{ps.n, ps.mean, ps.m2, ps.done}
+	}
+	for k := range f.splats {
+		sp := &f.splats[k]
+		s.Splats = append(s.Splats, sp.rgb[0].Load(), sp.rgb[1].Load(), sp.rgb[2].Load(), sp.weight.Load())
+	}
+	return s
+}
+
+func (f *film) restore(s filmState) error {
+	if s.Width != f.width || s.Height != f.height || len(s.Pixels) != len(f.pixels) || len(s.Splats) != 4*len(f.splats) {
+		return fmt.Errorf("checkpoint film doesn't match the %dx%d image", f.width, f.height)
+	}
+	for k, ps := range s.Pixels {
+		f.pixels[k] = pixelStats{n: ps.N, mean: ps.Mean, m2: ps.M2, done: ps.Done}
+	}
+	for k := range f.splats {
+		sp := &f.splats[k]
+		sp.rgb[0].Store(s.Splats[4*k])
+		sp.rgb[1].Store(s.Splats[4*k+1])
+		sp.rgb[2].Store(s.Splats[4*k+2])
+		sp.weight.Store(s.Splats[4*k+3])
+	}
+	return nil
+}
+
+// minSamples returns the fewest samples taken by any pixel that adaptive
+// sampling hasn't stopped, or max if there are none.
+func (f *film) minSamples(max int) int {
+	least := max
+	for _, ps := range f.pixels {
+		if !ps.done {
+			least = min(least, ps.n)
+		}
+	}
+	return least
+}
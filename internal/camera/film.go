@@ -0,0 +1,119 @@
+package camera
+
+import (
+	"math"
+	"raytracer/internal/color"
+	"sync/atomic"
+)
+
+// fixedPointScale converts filtered sample values to the integers the film
+// accumulates. Integer sums don't depend on the order samples arrive in, so
+// splatting into pixels shared between workers stays deterministic.
+const fixedPointScale = 1 << 24
+
+// maxSplatValue bounds a single weighted sample so that sums can't overflow.
+const maxSplatValue = 1 << 20
+
+// film accumulates the samples of every pixel of an image, so that rendering
+// can continue over several passes. Each sample is splatted into the pixels
+// around it, weighted by the reconstruction filter.
+type film struct {
+	width, height int
+	filter        filter
+	pixels        []pixelStats // Statistics of the samples taken by each pixel
+	splats        []splat      // Filtered samples landing on each pixel
+}
+
+// splat holds a pixel's weighted sum of samples and sum of weights, in fixed
+// point.
+type splat struct {
+	rgb    [3]atomic.Int64
+	weight atomic.Int64
+}
+
+func newFilm(width, height int, f filter) *film {
+	return &film{
+		width:  width,
+		height: height,
+		filter: f,
+		pixels: make([]pixelStats, width*height),
+		splats: make([]splat, width*height),
+	}
+}
+
+// at returns the samples gathered so far for pixel i, j.
+func (f *film) at(i, j int) *pixelStats {
+	return &f.pixels[j*f.width+i]
+}
+
+// addSample splats a sample taken at offset dx, dy from the center of pixel
+// i, j into every pixel within the filter's radius. It's safe for concurrent
+// use.
+func (f *film) addSample(i, j int, dx, dy float64, sample color.Color) {
+	// The sample's position in continuous pixel coordinates, where pixel
+	// centers lie at whole numbers.
+	x := float64(i) + dx
+	y := float64(j) + dy
+	x0 := max(int(math.Ceil(x-f.filter.radius)), 0)
+	x1 := min(int(math.Floor(x+f.filter.radius)), f.width-1)
+	y0 := max(int(math.Ceil(y-f.filter.radius)), 0)
+	y1 := min(int(math.Floor(y+f.filter.radius)), f.height-1)
+
+	for py := y0; py <= y1; py++ {
+		for px := x0; px <= x1; px++ {
+			w := f.filter.weight(x-float64(px), y-float64(py))
+			if w == 0 {
+				continue
+			}
+			s := &f.splats[py*f.width+px]
+			for k := range s.rgb {
+				s.rgb[k].Add(toFixed(w * sample.At(k)))
+			}
+			s.weight.Add(toFixed(w))
+		}
+	}
+}
+
+// toFixed converts x to fixed point, clamping it to the range sums allow and
+// dropping NaNs.
+func toFixed(x float64) int64 {
+	if math.IsNaN(x) {
+		return 0
+	}
+	return int64(math.Max(-maxSplatValue, math.Min(x, maxSplatValue)) * fixedPointScale)
+}
+
+// image returns the filtered color of each pixel.
+func (f *film) image() *Framebuffer {
+	return f.develop(func(i, j int) color.Color {
+		s := &f.splats[j*f.width+i]
+		weight := s.weight.Load()
+		if weight <= 0 {
+			return color.NewColor(0, 0, 0)
+		}
+		// Negative filter lobes can leave a pixel slightly negative.
+		var c color.Color
+		for k := range s.rgb {
+			c.Set(k, math.Max(0, float64(s.rgb[k].Load())/float64(weight)))
+		}
+		return c
+	})
+}
+
+// heatmap returns colors showing the fraction of maxSamples each pixel has
+// taken.
+func (f *film) heatmap(maxSamples int) *Framebuffer {
+	return f.develop(func(i, j int) color.Color {
+		return heatColor(float64(f.at(i, j).n) / float64(maxSamples))
+	})
+}
+
+func (f *film) develop(pixel func(i, j int) color.Color) *Framebuffer {
+	fb := NewFramebuffer(f.width, f.height)
+	for j := 0; j < f.height; j++ {
+		for i := 0; i < f.width; i++ {
+			fb.Set(i, j, pixel(i, j))
+		}
+	}
+	return fb
+}
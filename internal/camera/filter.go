@@ -0,0 +1,122 @@
+package camera
+
+import (
+	"fmt"
+	"math"
+)
+
+// Filter selects how samples are weighted into the pixels around them when
+// reconstructing the image
+type Filter int
+
+const (
+	// BoxFilter weights every sample within the radius equally. With the
+	// default radius of half a pixel, each sample only counts towards its
+	// own pixel.
+	BoxFilter Filter = iota
+	// TentFilter falls off linearly to zero at the radius, 1 by default.
+	TentFilter
+	// GaussianFilter falls off smoothly, with a standard deviation of a third
+	// of the radius, 1.5 by default.
+	GaussianFilter
+	// MitchellFilter is the Mitchell-Netravali cubic with B = C = 1/3, which
+	// balances sharpness against ringing. Its default radius is 2.
+	MitchellFilter
+	// LanczosFilter is a windowed sinc that keeps the most detail, at the cost
+	// of some ringing around edges. Its default radius is 3.
+	LanczosFilter
+)
+
+var filterNames = map[string]Filter{
+	"box":      BoxFilter,
+	"tent":     TentFilter,
+	"gaussian": GaussianFilter,
+	"mitchell": MitchellFilter,
+	"lanczos":  LanczosFilter,
+}
+
+// ParseFilter returns the Filter with the given lowercase name, such as
+// "mitchell".
+func ParseFilter(name string) (Filter, error) {
+	f, ok := filterNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown filter %q", name)
+	}
+	return f, nil
+}
+
+// defaultRadius returns the radius in pixels the filter is usually used with.
+func (f Filter) defaultRadius() float64 {
+	switch f {
+	case TentFilter:
+		return 1
+	case GaussianFilter:
+		return 1.5
+	case MitchellFilter:
+		return 2
+	case LanczosFilter:
+		return 3
+	}
+	return 0.5
+}
+
+// filter is a reconstruction filter of a given radius, applied separably in x
+// and y.
+type filter struct {
+	kind   Filter
+	radius float64
+}
+
+func newFilter(kind Filter, radius float64) filter {
+	if radius == 0 {
+		radius = kind.defaultRadius()
+	}
+	return filter{kind, radius}
+}
+
+// weight returns the filter's weight for a sample offset dx, dy pixels from a
+// pixel's center.
+func (f filter) weight(dx, dy float64) float64 {
+	return f.eval(dx) * f.eval(dy)
+}
+
+// eval returns the one-dimensional filter at offset x.
+func (f filter) eval(x float64) float64 {
+	x = math.Abs(x)
+	if x > f.radius {
+		return 0
+	}
+	switch f.kind {
+	case TentFilter:
+		return 1 - x/f.radius
+	case GaussianFilter:
+		// Subtract the value at the radius so the filter reaches zero there.
+		sigma := f.radius / 3
+		gaussian := func(x float64) float64 {
+			return math.Exp(-x * x / (2 * sigma * sigma))
+		}
+		return gaussian(x) - gaussian(f.radius)
+	case MitchellFilter:
+		return mitchell(2 * x / f.radius)
+	case LanczosFilter:
+		return sinc(x) * sinc(x/f.radius)
+	}
+	return 1
+}
+
+// mitchell evaluates the Mitchell-Netravali cubic with B = C = 1/3 at x in [0,2].
+func mitchell(x float64) float64 {
+	const b, c = 1.0 / 3, 1.0 / 3
+	if x > 1 {
+		return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+	}
+	return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+}
+
+// sinc returns the normalized sinc function, sin(πx)/(πx).
+func sinc(x float64) float64 {
+	if math.Abs(x) < 1e-5 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
@@ -0,0 +1,54 @@
+package camera
+
+import (
+	"fmt"
+	"io"
+	"raytracer/internal/color"
+)
+
+// Framebuffer is a rendered image, holding a linear color for each pixel
+type Framebuffer struct {
+	width, height int
+	pixels        []color.Color
+}
+
+// NewFramebuffer creates a black framebuffer of the given size
+func NewFramebuffer(width, height int) *Framebuffer {
+	return &Framebuffer{width, height, make([]color.Color, width*height)}
+}
+
+func (fb *Framebuffer) Width() int  { return fb.width }
+func (fb *Framebuffer) Height() int { return fb.height }
+
+// At returns the color of pixel x, y, counting from the top left
+func (fb *Framebuffer) At(x, y int) color.Color {
+	return fb.pixels[y*fb.width+x]
+}
+
+// Set sets the color of pixel x, y
+func (fb *Framebuffer) Set(x, y int, c color.Color) {
+	fb.pixels[y*fb.width+x] = c
+}
+
+// draw copies src into fb with its top left corner at x, y.
+func (fb *Framebuffer) draw(src *Framebuffer, x, y int) {
+	for j := 0; j < src.height; j++ {
+		copy(fb.pixels[(y+j)*fb.width+x:], src.pixels[j*src.width:(j+1)*src.width])
+	}
+}
+
+// WritePPM writes the framebuffer as a plain PPM image
+func (fb *Framebuffer) WritePPM(out io.Writer) error {
+	// Write header
+	if _, err := fmt.Fprintf(out, "P3\n%d %d\n255\n", fb.width, fb.height); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	// Write the results in order
+	for _, pixel := range fb.pixels {
+		if err := color.WriteColor(out, pixel); err != nil {
+			return fmt.Errorf("failed to write pixel: %w", err)
+		}
+	}
+	return nil
+}
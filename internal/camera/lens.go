@@ -0,0 +1,340 @@
+package camera
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"raytracer/internal/ray"
+	"raytracer/internal/vector"
+	"strconv"
+	"strings"
+)
+
+// LensSystem is a multi-element lens described by a prescription, traced with
+// real refraction at each spherical surface. This gives the distortion,
+// vignetting and bokeh of an actual camera lens.
+//
+// Lens space is measured in millimeters, with the film at z=0 and the lens
+// extending towards negative z. Scene units are taken to be meters.
+// https://pbr-book.org/3ed-2018/Camera_Models/Realistic_Cameras
+type LensSystem struct {
+	elements     []lensElement
+	filmDiagonal float64 // Diagonal of the film in millimeters
+}
+
+// lensElement is one refracting surface, or the aperture stop.
+type lensElement struct {
+	curvatureRadius float64 // Radius of the spherical surface; 0 for the aperture stop
+	thickness       float64 // Distance along the axis to the next element, or to the film
+	eta             float64 // Refractive index of the medium behind this surface
+	apertureRadius  float64 // Radius of the element's clear aperture
+}
+
+// LoadLens reads a lens prescription from a file.
+func LoadLens(path string, filmDiagonal float64) (*LensSystem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lens file: %w", err)
+	}
+	defer f.Close()
+
+	lens, err := ParseLens(f, filmDiagonal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lens file %s: %w", path, err)
+	}
+	return lens, nil
+}
+
+// ParseLens parses a lens prescription. Each line describes one surface, from
+// the front of the lens to the back, as four numbers in millimeters: the
+// curvature radius, the thickness to the next surface, the refractive index
+// behind the surface (0 for air) and the aperture diameter. A curvature radius
+// of 0 marks the aperture stop. Lines starting with # are comments.
+// filmDiagonal is the film size, also in millimeters (35mm film is 43.3).
+func ParseLens(r io.Reader, filmDiagonal float64) (*LensSystem, error) {
+	if filmDiagonal <= 0 {
+		return nil, fmt.Errorf("film diagonal must be positive, got %v", filmDiagonal)
+	}
+
+	lens := &LensSystem{filmDiagonal: filmDiagonal}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected 4 values, got %d", lineNum, len(fields))
+		}
+		var values [4]float64
+		for i, field := range fields {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid number %q", lineNum, field)
+			}
+			values[i] = v
+		}
+
+		eta := values[2]
+		if eta == 0 {
+			eta = 1
+		}
+		lens.elements = append(lens.elements, lensElement{
+			curvatureRadius: values[0],
+			thickness:       values[1],
+			eta:             eta,
+			apertureRadius:  values[3] / 2,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lens.elements) == 0 {
+		return nil, fmt.Errorf("no lens elements")
+	}
+	return lens, nil
+}
+
+// frontZ is the distance from the film to the front of the lens.
+func (l *LensSystem) frontZ() float64 {
+	z := 0.0
+	for _, e := range l.elements {
+		z += e.thickness
+	}
+	return z
+}
+
+// rearZ is the distance from the film to the rear element.
+func (l *LensSystem) rearZ() float64 {
+	return l.elements[len(l.elements)-1].thickness
+}
+
+// focused returns a copy of the lens with the film moved so that objects at
+// focusDist scene units are sharp, using a thick lens approximation.
+func (l *LensSystem) focused(focusDist float64) (*LensSystem, error) {
+	pz, fz, err := l.cardinalPoints()
+	if err != nil {
+		return nil, err
+	}
+
+	f := fz[0] - pz[0]
+	z := -focusDist * 1000
+	c := (pz[1] - z - pz[0]) * (pz[1] - z - 4*f - pz[0])
+	if c <= 0 {
+		return nil, fmt.Errorf("focus distance %v is too close for the lens", focusDist)
+	}
+	delta := 0.5 * (pz[1] - z + pz[0] - math.Sqrt(c))
+
+	focused := &LensSystem{
+		elements:     append([]lensElement{}, l.elements...),
+		filmDiagonal: l.filmDiagonal,
+	}
+	focused.elements[len(focused.elements)-1].thickness += delta
+	return focused, nil
+}
+
+// cardinalPoints finds the principal planes and focal points of the lens, on
+// the scene side ([0]) and the film side ([1]), by tracing rays parallel to
+// the axis through it in each direction.
+func (l *LensSystem) cardinalPoints() ([2]float64, [2]float64, error) {
+	var pz, fz [2]float64
+	x := 0.001 * l.filmDiagonal
+
+	sceneRay := lensRay{vector.NewVec3(x, 0, l.frontZ()+1), vector.NewVec3(0, 0, -1)}
+	filmRay, ok := l.traceFromScene(sceneRay)
+	if !ok {
+		return pz, fz, fmt.Errorf("paraxial ray from the scene was blocked")
+	}
+	pz[0], fz[0] = cardinalPoint(sceneRay, filmRay)
+
+	filmRay = lensRay{vector.NewVec3(x, 0, l.rearZ()-1), vector.NewVec3(0, 0, 1)}
+	sceneRay, ok = l.traceFromFilm(filmRay)
+	if !ok {
+		return pz, fz, fmt.Errorf("paraxial ray from the film was blocked")
+	}
+	pz[1], fz[1] = cardinalPoint(filmRay, sceneRay)
+
+	return pz, fz, nil
+}
+
+// cardinalPoint returns the principal plane and focal point for a ray parallel
+// to the axis entering the lens as rIn and leaving it as rOut.
+func cardinalPoint(rIn, rOut lensRay) (float64, float64) {
+	tf := -rOut.origin.X() / rOut.direction.X()
+	fz := -rOut.at(tf).Z()
+	tp := (rIn.origin.X() - rOut.origin.X()) / rOut.direction.X()
+	pz := -rOut.at(tp).Z()
+	return pz, fz
+}
+
+// lensRay is a ray in the lens's camera space, where z points into the scene.
+type lensRay struct {
+	origin, direction vector.Vec3
+}
+
+func (r lensRay) at(t float64) vector.Vec3 {
+	return r.origin.Add(r.direction.Scale(t))
+}
+
+// flipZ converts between camera space and lens space.
+func (r lensRay) flipZ() lensRay {
+	return lensRay{
+		vector.NewVec3(r.origin.X(), r.origin.Y(), -r.origin.Z()),
+		vector.NewVec3(r.direction.X(), r.direction.Y(), -r.direction.Z()),
+	}
+}
+
+// traceFromFilm follows a ray from the film out through the front of the lens,
+// reporting false if it is blocked by an element's or the stop's aperture.
+func (l *LensSystem) traceFromFilm(r lensRay) (lensRay, bool) {
+	r = r.flipZ()
+	elementZ := 0.0
+	for i := len(l.elements) - 1; i >= 0; i-- {
+		e := l.elements[i]
+		elementZ -= e.thickness
+
+		etaI := e.eta
+		etaT := 1.0
+		if i > 0 {
+			etaT = l.elements[i-1].eta
+		}
+
+		var ok bool
+		if r, ok = traceElement(r, e, elementZ, etaI, etaT); !ok {
+			return lensRay{}, false
+		}
+	}
+	return r.flipZ(), true
+}
+
+// traceFromScene follows a ray from the scene through the lens to the film.
+func (l *LensSystem) traceFromScene(r lensRay) (lensRay, bool) {
+	r = r.flipZ()
+	elementZ := -l.frontZ()
+	for i, e := range l.elements {
+		etaI := 1.0
+		if i > 0 {
+			etaI = l.elements[i-1].eta
+		}
+
+		var ok bool
+		if r, ok = traceElement(r, e, elementZ, etaI, e.eta); !ok {
+			return lensRay{}, false
+		}
+		elementZ += e.thickness
+	}
+	return r.flipZ(), true
+}
+
+// traceElement intersects a lens space ray with the element at elementZ and
+// refracts it from index etaI to etaT.
+func traceElement(r lensRay, e lensElement, elementZ, etaI, etaT float64) (lensRay, bool) {
+	var t float64
+	var normal vector.Vec3
+	if e.curvatureRadius == 0 {
+		// The aperture stop is a flat disk.
+		if r.direction.Z() == 0 {
+			return lensRay{}, false
+		}
+		t = (elementZ - r.origin.Z()) / r.direction.Z()
+	} else {
+		var ok bool
+		t, normal, ok = intersectSphericalElement(r, e.curvatureRadius, elementZ+e.curvatureRadius)
+		if !ok {
+			return lensRay{}, false
+		}
+	}
+	if t <= 0 {
+		return lensRay{}, false
+	}
+
+	hit := r.at(t)
+	if hit.X()*hit.X()+hit.Y()*hit.Y() > e.apertureRadius*e.apertureRadius {
+		return lensRay{}, false
+	}
+	if e.curvatureRadius == 0 {
+		return lensRay{hit, r.direction}, true
+	}
+
+	// Refract, failing on total internal reflection.
+	d := r.direction.Unit()
+	cosI := -vector.Dot(d, normal)
+	ratio := etaI / etaT
+	sin2T := ratio * ratio * math.Max(0, 1-cosI*cosI)
+	if sin2T >= 1 {
+		return lensRay{}, false
+	}
+	cosT := math.Sqrt(1 - sin2T)
+	refracted := d.Scale(ratio).Add(normal.Scale(ratio*cosI - cosT))
+	return lensRay{hit, refracted}, true
+}
+
+// intersectSphericalElement intersects a ray with a lens surface of the given
+// curvature radius centered on the axis at zCenter. The normal faces against
+// the ray.
+func intersectSphericalElement(r lensRay, radius, zCenter float64) (float64, vector.Vec3, bool) {
+	o := r.origin.Sub(vector.NewVec3(0, 0, zCenter))
+	a := r.direction.LengthSquared()
+	b := 2 * vector.Dot(r.direction, o)
+	c := o.LengthSquared() - radius*radius
+	discriminant := b*b - 4*a*c
+	if discriminant < 0 {
+		return 0, vector.Vec3{}, false
+	}
+
+	sqrtd := math.Sqrt(discriminant)
+	t0 := (-b - sqrtd) / (2 * a)
+	t1 := (-b + sqrtd) / (2 * a)
+
+	// Only one of the two intersections lies on the surface the ray meets.
+	useCloser := (r.direction.Z() > 0) != (radius < 0)
+	t := math.Max(t0, t1)
+	if useCloser {
+		t = math.Min(t0, t1)
+	}
+	if t < 0 {
+		return 0, vector.Vec3{}, false
+	}
+
+	normal := o.Add(r.direction.Scale(t)).Unit()
+	if vector.Dot(normal, r.direction) > 0 {
+		normal = normal.Neg()
+	}
+	return t, normal, true
+}
+
+// lensRay returns a scene ray for the image position x, y in [0,1], traced
+// from the film through the lens system towards the point u, v in [0,1) on the
+// rear element. It reports false if the lens blocks the ray.
+func (c *Camera) lensRay(x, y, u, v float64) (ray.Ray, bool) {
+	lens := c.lens
+
+	// The lens inverts the image, so positions on the film are flipped.
+	aspect := float64(c.config.ImageWidth) / float64(c.imageHeight)
+	filmHeight := lens.filmDiagonal / math.Sqrt(1+aspect*aspect)
+	filmWidth := filmHeight * aspect
+	pFilm := vector.NewVec3((0.5-x)*filmWidth, (y-0.5)*filmHeight, 0)
+
+	// Aim at the sampled point on the rear element.
+	rear := lens.elements[len(lens.elements)-1]
+	disk := vector.DiskFromSquare(u, v)
+	pRear := vector.NewVec3(disk.X()*rear.apertureRadius, disk.Y()*rear.apertureRadius, lens.rearZ())
+
+	out, ok := lens.traceFromFilm(lensRay{pFilm, pRear.Sub(pFilm)})
+	if !ok {
+		return ray.Ray{}, false
+	}
+
+	// Convert from lens camera space, in millimeters, to the scene.
+	toScene := func(v vector.Vec3) vector.Vec3 {
+		return c.basis.u.Scale(v.X()).
+			Add(c.basis.v.Scale(v.Y())).
+			Sub(c.basis.w.Scale(v.Z()))
+	}
+	origin := c.center.Add(toScene(out.origin).Scale(0.001))
+	return ray.NewRay(origin, toScene(out.direction)), true
+}
@@ -0,0 +1,73 @@
+package camera
+
+import (
+	"time"
+)
+
+// Progress describes how far a render has got
+type Progress struct {
+	TilesDone int           // Tiles rendered so far, counting every pass and stereo eye
+	Tiles     int           // Tiles the whole render will take
+	Samples   int64         // Camera samples taken so far
+	Rays      int64         // Rays traced so far, including shadow rays
+	Elapsed   time.Duration // Time since rendering started
+	ETA       time.Duration // Estimated time left, or zero before it can be estimated
+}
+
+// Fraction returns how much of the render is done, from 0 to 1.
+func (p Progress) Fraction() float64 {
+	if p.Tiles == 0 {
+		return 1
+	}
+	return float64(p.TilesDone) / float64(p.Tiles)
+}
+
+// progressTracker totals the work done across tiles and reports it.
+type progressTracker struct {
+	start    time.Time
+	progress Progress
+	stats    Stats
+	report   func(Progress)
+}
+
+func newProgressTracker(tiles int, report func(Progress)) *progressTracker {
+	return &progressTracker{
+		start:    time.Now(),
+		progress: Progress{Tiles: tiles},
+		report:   report,
+	}
+}
+
+// tileResult is the work a worker did on a tile, which it may not have
+// finished if rendering was canceled.
+type tileResult struct {
+	stats    Stats
+	finished bool
+}
+
+// add adds the work done on a tile and reports the new progress.
+func (pt *progressTracker) add(result tileResult) {
+	pt.stats.add(result.stats)
+	if !result.finished {
+		return
+	}
+	p := &pt.progress
+	p.TilesDone++
+	p.Samples = pt.stats.Samples
+	p.Rays = pt.stats.Rays()
+	p.Elapsed = time.Since(pt.start)
+	p.ETA = 0
+	if p.TilesDone > 0 {
+		p.ETA = time.Duration(float64(p.Elapsed) * float64(p.Tiles-p.TilesDone) / float64(p.TilesDone))
+	}
+	if pt.report != nil {
+		pt.report(*p)
+	}
+}
+
+// total returns the statistics of the work done so far.
+func (pt *progressTracker) total() Stats {
+	stats := pt.stats
+	stats.Duration = time.Since(pt.start)
+	return stats
+}
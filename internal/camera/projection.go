@@ -0,0 +1,103 @@
+package camera
+
+import (
+	"math"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+)
+
+// Projection selects how rays are generated from image positions
+type Projection int
+
+const (
+	// Perspective rays diverge from the camera center, covering VerticalFOV
+	Perspective Projection = iota
+	// Orthographic rays are parallel, covering ViewWidth scene units
+	Orthographic
+	// Equirectangular maps longitude and latitude linearly to x and y,
+	// covering the full sphere. Use an aspect ratio of 2.
+	Equirectangular
+	// FisheyeEquidistant maps the angle from the view direction linearly to
+	// the distance from the image center, covering FisheyeFOV.
+	FisheyeEquidistant
+	// FisheyeEquisolid preserves solid angle, so equal areas in the image see
+	// equal areas of the sphere, covering FisheyeFOV.
+	FisheyeEquisolid
+	// CubeMap renders six square 90 degree faces in a 3x2 grid: right, left
+	// and up on the top row, down, front and back on the bottom row. The image
+	// width must be a multiple of 3, and AspectRatio is ignored.
+	CubeMap
+)
+
+func (p Projection) isFisheye() bool {
+	return p == FisheyeEquidistant || p == FisheyeEquisolid
+}
+
+func (p Projection) isPanoramic() bool {
+	return p == Equirectangular || p.isFisheye() || p == CubeMap
+}
+
+// panoramicDirection returns the ray direction through the image position x, y
+// (in pixels) for the panoramic projections, and false if it lies outside the
+// projection.
+func (c *Camera) panoramicDirection(x, y float64) (vector.Vec3, bool) {
+	width := float64(c.config.ImageWidth)
+	height := float64(c.imageHeight)
+	right, up, forward := c.basis.u, c.basis.v, c.basis.w.Neg()
+
+	switch c.config.Projection {
+	case Equirectangular:
+		longitude := (x/width - 0.5) * 2 * math.Pi
+		latitude := (0.5 - y/height) * math.Pi
+		return right.Scale(math.Cos(latitude) * math.Sin(longitude)).
+			Add(up.Scale(math.Sin(latitude))).
+			Add(forward.Scale(math.Cos(latitude) * math.Cos(longitude))), true
+
+	case FisheyeEquidistant, FisheyeEquisolid:
+		// Normalize so the image circle touches the shorter image edges.
+		size := math.Min(width, height)
+		px := (x - width/2) / (size / 2)
+		py := (height/2 - y) / (size / 2)
+		r := math.Sqrt(px*px + py*py)
+		if r > 1 {
+			return vector.Vec3{}, false
+		}
+
+		maxTheta := util.DegreesToRadians(c.config.FisheyeFOV) / 2
+		theta := r * maxTheta
+		if c.config.Projection == FisheyeEquisolid {
+			theta = 2 * math.Asin(r*math.Sin(maxTheta/2))
+		}
+		phi := math.Atan2(py, px)
+		return right.Scale(math.Sin(theta) * math.Cos(phi)).
+			Add(up.Scale(math.Sin(theta) * math.Sin(phi))).
+			Add(forward.Scale(math.Cos(theta))), true
+
+	case CubeMap:
+		faceSize := width / 3
+		col := min(int(x/faceSize), 2)
+		row := min(int(y/faceSize), 1)
+		// Position within the face, in [-1,1] with b pointing up.
+		a := 2*(x-float64(col)*faceSize)/faceSize - 1
+		b := 1 - 2*(y-float64(row)*faceSize)/faceSize
+
+		var faceForward, faceRight, faceUp vector.Vec3
+		switch row*3 + col {
+		case 0: // Right
+			faceForward, faceRight, faceUp = right, forward.Neg(), up
+		case 1: // Left
+			faceForward, faceRight, faceUp = right.Neg(), forward, up
+		case 2: // Up
+			faceForward, faceRight, faceUp = up, right, forward.Neg()
+		case 3: // Down
+			faceForward, faceRight, faceUp = up.Neg(), right, forward
+		case 4: // Front
+			faceForward, faceRight, faceUp = forward, right, up
+		default: // Back
+			faceForward, faceRight, faceUp = forward.Neg(), right.Neg(), up
+		}
+		return faceForward.Add(faceRight.Scale(a)).Add(faceUp.Scale(b)), true
+	}
+
+	return vector.Vec3{}, false
+}
@@ -0,0 +1,119 @@
+package camera
+
+import (
+	"fmt"
+	"io"
+	"raytracer/internal/core"
+	"raytracer/internal/hittable"
+	"raytracer/internal/interval"
+	"raytracer/internal/ray"
+	"time"
+)
+
+// Stats counts the work done by a render. Workers each count into their own
+// Stats for every tile, which are then added up, so counting needs no
+// synchronization.
+type Stats struct {
+	Duration time.Duration // Wall-clock time taken by Render
+
+	Samples       int64 // Camera samples taken
+	PrimaryRays   int64 // Rays traced from the camera
+	SecondaryRays int64 // Rays traced after scattering off a surface
+	ShadowRays    int64 // Rays traced towards lights
+
+	// MaxDepthTerminations counts paths cut off by MaxDepth while still
+	// scattering.
+	MaxDepthTerminations int64
+
+	// IntersectionTests counts tests of rays against individual objects, and
+	// NodeVisits the acceleration structure nodes visited finding them. Both
+	// are only counted for worlds that implement hittable.Counted.
+	IntersectionTests int64
+	NodeVisits        int64
+}
+
+// Rays returns the total number of rays traced.
+func (st Stats) Rays() int64 {
+	return st.PrimaryRays + st.SecondaryRays + st.ShadowRays
+}
+
+// RaysPerSecond returns the rate rays were traced at.
+func (st Stats) RaysPerSecond() float64 {
+	if st.Duration <= 0 {
+		return 0
+	}
+	return float64(st.Rays()) / st.Duration.Seconds()
+}
+
+// AveragePathDepth returns the mean number of segments in the paths traced
+// from the camera, not counting shadow rays.
+func (st Stats) AveragePathDepth() float64 {
+	return ratio(st.PrimaryRays+st.SecondaryRays, st.PrimaryRays)
+}
+
+// TestsPerRay returns the mean number of intersection tests for each ray.
+func (st Stats) TestsPerRay() float64 {
+	return ratio(st.IntersectionTests, st.Rays())
+}
+
+// NodeVisitsPerRay returns the mean number of acceleration structure nodes
+// visited for each ray.
+func (st Stats) NodeVisitsPerRay() float64 {
+	return ratio(st.NodeVisits, st.Rays())
+}
+
+func ratio(a, b int64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func (st *Stats) add(other Stats) {
+	st.Samples += other.Samples
+	st.PrimaryRays += other.PrimaryRays
+	st.SecondaryRays += other.SecondaryRays
+	st.ShadowRays += other.ShadowRays
+	st.MaxDepthTerminations += other.MaxDepthTerminations
+	st.IntersectionTests += other.IntersectionTests
+	st.NodeVisits += other.NodeVisits
+}
+
+// WriteSummary writes a human-readable summary of the statistics.
+func (st Stats) WriteSummary(w io.Writer) error {
+	nodeVisits := "n/a (no acceleration structure)"
+	if st.NodeVisits > 0 {
+		nodeVisits = fmt.Sprintf("%.1f", st.NodeVisitsPerRay())
+	}
+	_, err := fmt.Fprintf(w, `Render statistics:
+  Time:                   %v
+  Camera samples:         %d
+  Rays traced:            %d (%.0f per second)
+    Primary:              %d
+    Secondary:            %d
+    Shadow:               %d
+  Average path depth:     %.2f
+  Stopped by max depth:   %d
+  Intersection tests/ray: %.1f
+  BVH node visits/ray:    %s
+`,
+		st.Duration.Round(time.Millisecond), st.Samples,
+		st.Rays(), st.RaysPerSecond(),
+		st.PrimaryRays, st.SecondaryRays, st.ShadowRays,
+		st.AveragePathDepth(), st.MaxDepthTerminations,
+		st.TestsPerRay(), nodeVisits)
+	return err
+}
+
+// hit finds the nearest hit of r in the world, counting the work done.
+func (s scene) hit(r ray.Ray, rayT interval.Interval, rec *core.HitRecord, st *Stats) bool {
+	counted, ok := s.world.(hittable.Counted)
+	if !ok {
+		return s.world.Hit(r, rayT, rec)
+	}
+	var counters hittable.Counters
+	hit := counted.HitCounted(r, rayT, rec, &counters)
+	st.IntersectionTests += counters.Tests
+	st.NodeVisits += counters.NodeVisits
+	return hit
+}
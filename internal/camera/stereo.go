@@ -0,0 +1,91 @@
+package camera
+
+import (
+	"raytracer/internal/vector"
+)
+
+// StereoMode selects whether to render one image or a pair for the two eyes,
+// and how the pair is laid out in the output.
+type StereoMode int
+
+const (
+	// Mono renders a single image
+	Mono StereoMode = iota
+	// SideBySide places the left eye image to the left of the right eye image
+	SideBySide
+	// TopBottom places the left eye image above the right eye image
+	TopBottom
+)
+
+// Convergence selects how the two eyes' views are made to meet at the
+// convergence distance.
+type Convergence int
+
+const (
+	// OffAxis keeps the eyes parallel and shifts each eye's view sideways.
+	// This avoids the vertical parallax that toe-in introduces.
+	OffAxis Convergence = iota
+	// ToeIn rotates each eye to look at the convergence point
+	ToeIn
+)
+
+// newEyes creates cameras for the left and right eyes of a stereo pair.
+func newEyes(cfg Config) ([2]*Camera, error) {
+	cfg.StereoMode = Mono
+
+	// The eyes are offset along the camera's horizontal axis, computed the same
+	// way as in initialize.
+	w := cfg.LookFrom.Sub(cfg.LookAt).Unit()
+	u := vector.Cross(cfg.VUp, w).Unit()
+
+	convergenceDist := cfg.ConvergenceDist
+	if convergenceDist <= 0 {
+		convergenceDist = cfg.FocusDist
+	}
+	convergencePoint := cfg.LookFrom.Sub(w.Scale(convergenceDist))
+
+	var eyes [2]*Camera
+	for i, side := range [2]float64{-1, 1} {
+		offset := side * cfg.InterocularDistance / 2
+		eye := &Camera{config: cfg, exposureScale: exposure(cfg)}
+		// Give each eye its own random streams so their noise is independent.
+		eye.streamOffset = uint64(i+1) << 32
+
+		if cfg.Projection.isPanoramic() {
+			// Omnidirectional stereo: every ray starts on a circle around the
+			// center, offset perpendicular to its own direction.
+			eye.eyeOffset = offset
+		} else {
+			eye.config.LookFrom = cfg.LookFrom.Add(u.Scale(offset))
+			if cfg.Convergence == ToeIn {
+				eye.config.LookAt = convergencePoint
+			} else {
+				eye.config.LookAt = cfg.LookAt.Add(u.Scale(offset))
+				// Shift the view back towards the center so the convergence
+				// point is centered in both images.
+				eye.viewportShift = -offset * cfg.FocusDist / convergenceDist
+			}
+		}
+
+		if err := eye.initialize(); err != nil {
+			return eyes, err
+		}
+		eyes[i] = eye
+	}
+	return eyes, nil
+}
+
+// composite joins the left and right eye images according to the mode.
+func composite(mode StereoMode, left, right *Framebuffer) *Framebuffer {
+	if mode == TopBottom {
+		image := NewFramebuffer(left.width, left.height+right.height)
+		image.draw(left, 0, 0)
+		image.draw(right, 0, left.height)
+		return image
+	}
+
+	image := NewFramebuffer(left.width+right.width, left.height)
+	image.draw(left, 0, 0)
+	image.draw(right, left.width, 0)
+	return image
+}
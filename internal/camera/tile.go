@@ -0,0 +1,215 @@
+package camera
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// defaultTileSize is the width and height of tiles, in pixels, when
+// Config.TileSize isn't set.
+const defaultTileSize = 32
+
+// TileOrder selects the order in which tiles are rendered
+type TileOrder int
+
+const (
+	// RowOrder renders tiles left to right, top to bottom
+	RowOrder TileOrder = iota
+	// SpiralOrder starts at the center of the image and spirals outwards, so
+	// that the subject usually appears first
+	SpiralOrder
+	// HilbertOrder follows a Hilbert curve, keeping consecutive tiles next to
+	// each other for better cache locality
+	HilbertOrder
+)
+
+var tileOrderNames = map[string]TileOrder{
+	"row":     RowOrder,
+	"spiral":  SpiralOrder,
+	"hilbert": HilbertOrder,
+}
+
+// ParseTileOrder returns the TileOrder with the given lowercase name, such as
+// "hilbert".
+func ParseTileOrder(name string) (TileOrder, error) {
+	order, ok := tileOrderNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown tile order %q", name)
+	}
+	return order, nil
+}
+
+// tile is a rectangle of pixels, from x0, y0 up to but excluding x1, y1.
+type tile struct {
+	x0, y0, x1, y1 int
+}
+
+// tiles divides a width by height image into tiles of the given size, in the
+// given order. Tiles on the right and bottom edges may be smaller.
+func tiles(width, height, size int, order TileOrder) []tile {
+	nx := (width + size - 1) / size
+	ny := (height + size - 1) / size
+
+	var coords [][2]int
+	switch order {
+	case SpiralOrder:
+		coords = spiral(nx, ny)
+	case HilbertOrder:
+		coords = hilbert(nx, ny)
+	default:
+		for ty := 0; ty < ny; ty++ {
+			for tx := 0; tx < nx; tx++ {
+				coords = append(coords, [2]int{tx, ty})
+			}
+		}
+	}
+
+	result := make([]tile, len(coords))
+	for k, c := range coords {
+		x0, y0 := c[0]*size, c[1]*size
+		result[k] = tile{x0, y0, min(x0+size, width), min(y0+size, height)}
+	}
+	return result
+}
+
+// spiral returns the cells of an nx by ny grid, walking a square spiral out
+// from the center.
+func spiral(nx, ny int) [][2]int {
+	coords := make([][2]int, 0, nx*ny)
+	x, y := (nx-1)/2, (ny-1)/2
+	dx, dy := 1, 0
+	for leg := 1; len(coords) < nx*ny; leg++ {
+		// Each length of leg is walked twice, turning after each.
+		for turn := 0; turn < 2; turn++ {
+			for step := 0; step < leg; step++ {
+				if x >= 0 && x < nx && y >= 0 && y < ny {
+					coords = append(coords, [2]int{x, y})
+				}
+				x, y = x+dx, y+dy
+			}
+			dx, dy = -dy, dx
+		}
+	}
+	return coords
+}
+
+// hilbert returns the cells of an nx by ny grid in the order a Hilbert curve
+// covering the grid visits them.
+func hilbert(nx, ny int) [][2]int {
+	n := 1 << bits.Len(uint(max(nx, ny)-1))
+	coords := make([][2]int, 0, nx*ny)
+	for ty := 0; ty < ny; ty++ {
+		for tx := 0; tx < nx; tx++ {
+			coords = append(coords, [2]int{tx, ty})
+		}
+	}
+	sort.Slice(coords, func(a, b int) bool {
+		return hilbertIndex(n, coords[a][0], coords[a][1]) < hilbertIndex(n, coords[b][0], coords[b][1])
+	})
+	return coords
+}
+
+// hilbertIndex returns the distance along the Hilbert curve of an n by n
+// grid, for n a power of two, at which it visits cell x, y.
+// https://en.wikipedia.org/wiki/Hilbert_curve
+func hilbertIndex(n, x, y int) int {
+	d := 0
+	for s := n / 2; s > 0; s /= 2 {
+		rx, ry := 0, 0
+		if x&s != 0 {
+			rx = 1
+		}
+		if y&s != 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+		// Rotate the quadrant so the curve inside it has the usual orientation.
+		if ry == 0 {
+			if rx == 1 {
+				x, y = s-1-x, s-1-y
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
+
+// scheduler hands out tiles to workers. Each worker has its own queue, dealt
+// tiles in turn so that all workers progress along the tile order together.
+// A worker whose queue runs dry steals from the back of the fullest queue, so
+// no worker sits idle while tiles are left.
+type scheduler struct {
+	queues []tileQueue
+}
+
+// tileQueue is a worker's double-ended queue of tiles.
+type tileQueue struct {
+	mu    sync.Mutex
+	tiles []tile
+}
+
+func newScheduler(ts []tile, workers int) *scheduler {
+	s := &scheduler{queues: make([]tileQueue, workers)}
+	for k, t := range ts {
+		q := &s.queues[k%workers]
+		q.tiles = append(q.tiles, t)
+	}
+	return s
+}
+
+// next returns the next tile for the given worker, and false once there are
+// none left anywhere.
+func (s *scheduler) next(worker int) (tile, bool) {
+	if t, ok := s.queues[worker].popFront(); ok {
+		return t, true
+	}
+	for {
+		victim := s.fullest()
+		if victim < 0 {
+			return tile{}, false
+		}
+		if t, ok := s.queues[victim].popBack(); ok {
+			return t, true
+		}
+	}
+}
+
+// fullest returns the index of the queue with the most tiles, or -1 if they're
+// all empty.
+func (s *scheduler) fullest() int {
+	best, most := -1, 0
+	for k := range s.queues {
+		q := &s.queues[k]
+		q.mu.Lock()
+		n := len(q.tiles)
+		q.mu.Unlock()
+		if n > most {
+			best, most = k, n
+		}
+	}
+	return best
+}
+
+func (q *tileQueue) popFront() (tile, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tiles) == 0 {
+		return tile{}, false
+	}
+	t := q.tiles[0]
+	q.tiles = q.tiles[1:]
+	return t, true
+}
+
+func (q *tileQueue) popBack() (tile, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tiles) == 0 {
+		return tile{}, false
+	}
+	t := q.tiles[len(q.tiles)-1]
+	q.tiles = q.tiles[:len(q.tiles)-1]
+	return t, true
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"math"
 	"raytracer/internal/interval"
+	"raytracer/internal/util"
 	"raytracer/internal/vector"
 )
 
@@ -35,12 +36,12 @@ func WriteColor(w io.Writer, pixelColor Color) error {
 	return err
 }
 
-func Random() Color {
-	return vector.Random()
+func Random(rng util.RNG) Color {
+	return vector.Random(rng)
 }
 
-func RandomFromRange(min, max float64) Color {
-	return vector.RandomFromRange(min, max)
+func RandomFromRange(rng util.RNG, min, max float64) Color {
+	return vector.RandomFromRange(rng, min, max)
 }
 
 func linearToGamma(linearComponent float64) float64 {
@@ -3,6 +3,7 @@ package core
 import (
 	"raytracer/internal/color"
 	"raytracer/internal/ray"
+	"raytracer/internal/util"
 	"raytracer/internal/vector"
 )
 
@@ -10,6 +11,9 @@ type HitRecord struct {
 	p         vector.Point3
 	normal    vector.Vec3
 	t         float64
+	u, v      float64
+	dpdu      vector.Vec3 // Surface tangent along u
+	dpdv      vector.Vec3 // Surface tangent along v
 	frontFace bool
 	mat       Material
 }
@@ -30,6 +34,31 @@ func (hr HitRecord) T() float64 {
 	return hr.t
 }
 
+// UV returns the surface coordinates of the hit point.
+func (hr HitRecord) UV() (float64, float64) {
+	return hr.u, hr.v
+}
+
+func (hr *HitRecord) SetUV(u, v float64) {
+	hr.u = u
+	hr.v = v
+}
+
+// Dpdu returns the partial derivative of the surface position with respect to u.
+func (hr HitRecord) Dpdu() vector.Vec3 {
+	return hr.dpdu
+}
+
+// Dpdv returns the partial derivative of the surface position with respect to v.
+func (hr HitRecord) Dpdv() vector.Vec3 {
+	return hr.dpdv
+}
+
+func (hr *HitRecord) SetTangents(dpdu, dpdv vector.Vec3) {
+	hr.dpdu = dpdu
+	hr.dpdv = dpdv
+}
+
 func (hr HitRecord) Material() Material {
 	return hr.mat
 }
@@ -51,10 +80,38 @@ func (hr *HitRecord) SetFaceNormal(r ray.Ray, outwardNormal vector.Vec3) {
 	}
 }
 
+// SetNormal replaces the normal without changing which face was hit. It is used
+// to perturb the shading normal, e.g. for normal and bump mapping.
+func (hr *HitRecord) SetNormal(normal vector.Vec3) {
+	hr.normal = normal
+}
+
 func (hr *HitRecord) SetMaterial(mat Material) {
 	hr.mat = mat
 }
 
 type Material interface {
-	Scatter(rIn ray.Ray, rec *HitRecord, attenuation *color.Color, scattered *ray.Ray) bool
+	Scatter(rIn ray.Ray, rec *HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool
+}
+
+// BSDF is implemented by materials that can report how much light they reflect
+// from an arbitrary direction, which is needed to light them with shadow rays.
+// Perfectly specular materials, which only reflect from a single direction,
+// don't implement it.
+type BSDF interface {
+	// Eval returns the fraction of light arriving from the unit direction wi
+	// that is scattered back along rIn, including the cosine term.
+	Eval(rIn ray.Ray, rec *HitRecord, wi vector.Vec3) color.Color
+}
+
+// Emitter is implemented by materials that give off light. Emission is seen by
+// rays that hit the surface, in addition to any light it scatters.
+type Emitter interface {
+	Emitted(rec *HitRecord) color.Color
+}
+
+// Masked is implemented by materials with an opacity mask. Points with an
+// opacity of 0 are treated as holes in the surface and rays pass through them.
+type Masked interface {
+	Opacity(rec *HitRecord) float64
 }
@@ -4,31 +4,54 @@ import (
 	"raytracer/internal/core"
 	"raytracer/internal/interval"
 	"raytracer/internal/ray"
+	"raytracer/internal/util"
 )
 
 type Hittable interface {
 	Hit(r ray.Ray, rayT interval.Interval, rec *core.HitRecord) bool
 }
 
+// Counters tally the work done finding a ray's nearest hit
+type Counters struct {
+	Tests      int64 // Intersection tests against individual objects
+	NodeVisits int64 // Nodes of acceleration structures visited
+}
+
+// Counted is implemented by aggregates of objects that can count the work
+// done by each query
+type Counted interface {
+	HitCounted(r ray.Ray, rayT interval.Interval, rec *core.HitRecord, counters *Counters) bool
+}
+
 type HittableList struct {
 	objects []Hittable
+	counted []Counted // Each object as a Counted, or nil if it isn't one
 }
 
 func NewHittableList() HittableList {
-	return HittableList{[]Hittable{}}
+	return HittableList{[]Hittable{}, []Counted{}}
 }
 
 func (hl *HittableList) Add(object Hittable) {
+	// Look up whether the object counts its own work once, rather than on
+	// every ray.
+	counted, _ := object.(Counted)
 	hl.objects = append(hl.objects, object)
+	hl.counted = append(hl.counted, counted)
 }
 
 func (hl HittableList) Hit(r ray.Ray, rayT interval.Interval, rec *core.HitRecord) bool {
+	var counters Counters
+	return hl.HitCounted(r, rayT, rec, &counters)
+}
+
+func (hl HittableList) HitCounted(r ray.Ray, rayT interval.Interval, rec *core.HitRecord, counters *Counters) bool {
 	var tempRec core.HitRecord
 	hitAnything := false
 	closestSoFar := rayT.Max()
 
-	for _, object := range hl.objects {
-		if object.Hit(r, interval.NewInterval(rayT.Min(), closestSoFar), &tempRec) {
+	for k, object := range hl.objects {
+		if hitOpaque(object, hl.counted[k], r, interval.NewInterval(rayT.Min(), closestSoFar), &tempRec, counters) {
 			hitAnything = true
 			closestSoFar = tempRec.T()
 			*rec = tempRec
@@ -37,3 +60,41 @@ func (hl HittableList) Hit(r ray.Ray, rayT interval.Interval, rec *core.HitRecor
 
 	return hitAnything
 }
+
+// hitOpaque finds the nearest hit on object that isn't masked out by its
+// material's opacity, continuing the ray past any holes. If the object is
+// Counted, counted is the same object, and counts its own work.
+func hitOpaque(object Hittable, counted Counted, r ray.Ray, rayT interval.Interval, rec *core.HitRecord, counters *Counters) bool {
+	for {
+		var hit bool
+		if counted != nil {
+			hit = counted.HitCounted(r, rayT, rec, counters)
+		} else {
+			counters.Tests++
+			hit = object.Hit(r, rayT, rec)
+		}
+		if !hit {
+			return false
+		}
+
+		masked, ok := rec.Material().(core.Masked)
+		if !ok {
+			return true
+		}
+
+		opacity := masked.Opacity(rec)
+		if opacity >= 1 {
+			return true
+		}
+		if opacity > 0 {
+			// Partial opacity is handled stochastically. The decision is derived
+			// from the ray and hit distance rather than a shared random source.
+			o, d := r.Origin(), r.Direction()
+			if util.HashFloat(o.X(), o.Y(), o.Z(), d.X(), d.Y(), d.Z(), rec.T()) < opacity {
+				return true
+			}
+		}
+
+		rayT = interval.NewInterval(rec.T(), rayT.Max())
+	}
+}
@@ -0,0 +1,68 @@
+package hittable
+
+import (
+	"math"
+	"raytracer/internal/core"
+	"raytracer/internal/interval"
+	"raytracer/internal/ray"
+	"raytracer/internal/vector"
+)
+
+// Quad is a parallelogram with corner q and edges u and v.
+type Quad struct {
+	q      vector.Point3
+	u, v   vector.Vec3
+	w      vector.Vec3 // Cached n / (n . n), used to find planar coordinates
+	normal vector.Vec3
+	d      float64 // Plane constant, such that normal . p = d
+	mat    core.Material
+}
+
+func NewQuad(q vector.Point3, u, v vector.Vec3, mat core.Material) Quad {
+	n := vector.Cross(u, v)
+	normal := n.Unit()
+	return Quad{
+		q:      q,
+		u:      u,
+		v:      v,
+		w:      n.Div(vector.Dot(n, n)),
+		normal: normal,
+		d:      vector.Dot(normal, q),
+		mat:    mat,
+	}
+}
+
+// https://raytracing.github.io/books/RayTracingTheNextWeek.html#quadrilaterals
+func (q Quad) Hit(r ray.Ray, rayT interval.Interval, rec *core.HitRecord) bool {
+	denom := vector.Dot(q.normal, r.Direction())
+
+	// No hit if the ray is parallel to the plane.
+	if math.Abs(denom) < 1e-8 {
+		return false
+	}
+
+	t := (q.d - vector.Dot(q.normal, r.Origin())) / denom
+	if !rayT.Surrounds(t) {
+		return false
+	}
+
+	// Determine if the hit point lies within the quad using its planar coordinates.
+	intersection := r.At(t)
+	planarHitVector := intersection.Sub(q.q)
+	alpha := vector.Dot(q.w, vector.Cross(planarHitVector, q.v))
+	beta := vector.Dot(q.w, vector.Cross(q.u, planarHitVector))
+
+	unit := interval.NewInterval(0, 1)
+	if alpha < unit.Min() || alpha > unit.Max() || beta < unit.Min() || beta > unit.Max() {
+		return false
+	}
+
+	rec.SetT(t)
+	rec.SetPoint(intersection)
+	rec.SetFaceNormal(r, q.normal)
+	rec.SetUV(alpha, beta)
+	rec.SetTangents(q.u, q.v)
+	rec.SetMaterial(q.mat)
+
+	return true
+}
@@ -9,13 +9,20 @@ import (
 )
 
 type Sphere struct {
-	center vector.Point3
-	radius float64
-	mat    core.Material
+	center   vector.Point3
+	velocity vector.Vec3 // Distance moved per unit of ray time, for motion blur
+	radius   float64
+	mat      core.Material
 }
 
 func NewSphere(center vector.Point3, radius float64, mat core.Material) Sphere {
-	return Sphere{center, math.Max(0.0, radius), mat}
+	return Sphere{center, vector.ZeroVec3(), math.Max(0.0, radius), mat}
+}
+
+// NewMovingSphere creates a sphere that is at center at time zero and moves
+// with a constant velocity, in scene units per second of exposure.
+func NewMovingSphere(center vector.Point3, velocity vector.Vec3, radius float64, mat core.Material) Sphere {
+	return Sphere{center, velocity, math.Max(0.0, radius), mat}
 }
 
 func (s Sphere) Center() vector.Point3 {
@@ -30,7 +37,8 @@ func (s Sphere) Radius() float64 {
 // https://raytracing.github.io/books/RayTracingInOneWeekend.html#addingasphere/ray-sphereintersection
 // https://raytracing.github.io/books/RayTracingInOneWeekend.html#surfacenormalsandmultipleobjects/simplifyingtheray-sphereintersectioncode
 func (s Sphere) Hit(r ray.Ray, rayT interval.Interval, rec *core.HitRecord) bool {
-	oc := s.center.Sub(r.Origin())
+	center := s.center.Add(s.velocity.Scale(r.Time()))
+	oc := center.Sub(r.Origin())
 	a := r.Direction().LengthSquared()
 	h := vector.Dot(r.Direction(), oc)
 	c := oc.LengthSquared() - s.radius*s.radius
@@ -53,9 +61,35 @@ func (s Sphere) Hit(r ray.Ray, rayT interval.Interval, rec *core.HitRecord) bool
 
 	rec.SetT(root)
 	rec.SetPoint(r.At(rec.T()))
-	outwardNormal := rec.Point().Sub(s.center).Div(s.radius)
+	outwardNormal := rec.Point().Sub(center).Div(s.radius)
 	rec.SetFaceNormal(r, outwardNormal)
+	rec.SetUV(sphereUV(outwardNormal))
+	rec.SetTangents(sphereTangents(outwardNormal.Scale(s.radius)))
 	rec.SetMaterial(s.mat)
 
 	return true
 }
+
+// sphereUV maps a point on the unit sphere to texture coordinates, with u
+// running around the Y axis starting from X=-1, and v from Y=-1 to Y=+1.
+// https://raytracing.github.io/books/RayTracingTheNextWeek.html#texturemapping/texturecoordinatesforspheres
+func sphereUV(p vector.Point3) (float64, float64) {
+	theta := math.Acos(-p.Y())
+	phi := math.Atan2(-p.Z(), p.X()) + math.Pi
+	return phi / (2 * math.Pi), theta / math.Pi
+}
+
+// sphereTangents returns the derivatives of a point on the sphere, relative to
+// its center, with respect to the u and v coordinates produced by sphereUV.
+func sphereTangents(p vector.Vec3) (vector.Vec3, vector.Vec3) {
+	x, y, z := p.X(), p.Y(), p.Z()
+	rho := math.Sqrt(x*x + z*z)
+	if rho < 1e-8 {
+		// u is undefined at the poles, so pick any tangent frame.
+		return vector.NewVec3(2*math.Pi*p.Length(), 0, 0), vector.NewVec3(0, 0, math.Pi*p.Length())
+	}
+
+	dpdu := vector.NewVec3(z, 0, -x).Scale(2 * math.Pi)
+	dpdv := vector.NewVec3(-x*y/rho, rho, -y*z/rho).Scale(math.Pi)
+	return dpdu, dpdv
+}
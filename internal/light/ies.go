@@ -0,0 +1,266 @@
+package light
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IESProfile is a luminaire's measured candela distribution, read from an IES
+// LM-63 photometric file. Vertical angles are measured from the nadir (the
+// direction the luminaire points) and horizontal angles around it.
+type IESProfile struct {
+	vertical   []float64   // Vertical angles in degrees, ascending
+	horizontal []float64   // Horizontal angles in degrees, ascending
+	candela    [][]float64 // Candela values indexed by [horizontal][vertical]
+	max        float64     // Largest candela value, for normalization
+}
+
+// LoadIES reads an IES profile from a file.
+func LoadIES(path string) (*IESProfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open IES file: %w", err)
+	}
+	defer f.Close()
+
+	profile, err := ParseIES(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse IES file %s: %w", path, err)
+	}
+	return profile, nil
+}
+
+// ParseIES parses an IES LM-63 file (1986, 1991, 1995 or 2002 revision).
+// Only type C photometry, which is used by nearly all architectural
+// luminaires, is supported.
+func ParseIES(r io.Reader) (*IESProfile, error) {
+	scanner := bufio.NewScanner(r)
+
+	// Skip the version line and keywords up to the TILT line.
+	tilt := ""
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(strings.ToUpper(line), "TILT=") {
+			tilt = strings.ToUpper(strings.TrimSpace(line[len("TILT="):]))
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if tilt == "" {
+		return nil, fmt.Errorf("missing TILT line")
+	}
+
+	// The rest of the file is numbers separated by whitespace or commas.
+	var numbers []float64
+	for scanner.Scan() {
+		fields := strings.FieldsFunc(scanner.Text(), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		for _, field := range fields {
+			n, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", field)
+			}
+			numbers = append(numbers, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	p := iesParser{numbers: numbers}
+
+	// Lamp tilt data only affects lamps mounted at an angle; skip it.
+	if tilt == "INCLUDE" {
+		p.next() // Lamp-to-luminaire geometry
+		pairs := p.nextInt()
+		p.skip(2 * pairs)
+	}
+
+	p.next() // Number of lamps
+	p.next() // Lumens per lamp
+	multiplier := p.next()
+	numVertical := p.nextInt()
+	numHorizontal := p.nextInt()
+	photometricType := p.nextInt()
+	p.skip(4) // Units, width, length, height
+	ballastFactor := p.next()
+	p.skip(2) // Ballast-lamp photometric factor, input watts
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	if photometricType != 1 {
+		return nil, fmt.Errorf("unsupported photometric type %d, only type C is supported", photometricType)
+	}
+	if numVertical < 1 || numHorizontal < 1 {
+		return nil, fmt.Errorf("invalid angle counts %d x %d", numVertical, numHorizontal)
+	}
+
+	profile := &IESProfile{
+		vertical:   p.nextN(numVertical),
+		horizontal: p.nextN(numHorizontal),
+		candela:    make([][]float64, numHorizontal),
+	}
+	for h := range profile.candela {
+		profile.candela[h] = p.nextN(numVertical)
+		for v := range profile.candela[h] {
+			profile.candela[h][v] *= multiplier * ballastFactor
+			profile.max = math.Max(profile.max, profile.candela[h][v])
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if !sort.Float64sAreSorted(profile.vertical) || !sort.Float64sAreSorted(profile.horizontal) {
+		return nil, fmt.Errorf("angles must be in ascending order")
+	}
+
+	return profile, nil
+}
+
+// Candela returns the luminous intensity towards the given vertical and
+// horizontal angles in degrees, interpolating bilinearly between measured
+// angles. Directions outside the measured vertical range emit no light.
+func (p *IESProfile) Candela(vertical, horizontal float64) float64 {
+	if vertical < p.vertical[0] || vertical > p.vertical[len(p.vertical)-1] {
+		return 0
+	}
+
+	h0, h1, ht := p.bracketHorizontal(horizontal)
+	v0, v1, vt := bracket(p.vertical, vertical)
+
+	lerp := func(a, b, t float64) float64 { return a + (b-a)*t }
+	c0 := lerp(p.candela[h0][v0], p.candela[h0][v1], vt)
+	c1 := lerp(p.candela[h1][v0], p.candela[h1][v1], vt)
+	return lerp(c0, c1, ht)
+}
+
+// Normalized returns the candela value in the given direction relative to the
+// profile's brightest direction.
+func (p *IESProfile) Normalized(vertical, horizontal float64) float64 {
+	if p.max <= 0 {
+		return 0
+	}
+	return p.Candela(vertical, horizontal) / p.max
+}
+
+// bracketHorizontal is bracket for a horizontal angle in any direction. Angles
+// are folded into the measured range by the profile's symmetry, and profiles
+// covering the full circle interpolate across the gap between their last angle
+// and their first, when they don't repeat it at 360 degrees.
+func (p *IESProfile) bracketHorizontal(horizontal float64) (int, int, float64) {
+	h, full := p.foldHorizontal(horizontal)
+	first, last := p.horizontal[0], p.horizontal[len(p.horizontal)-1]
+	if !full || (h >= first && h <= last) || last-first >= 360 {
+		return bracket(p.horizontal, h)
+	}
+	if h < first {
+		h += 360
+	}
+	return len(p.horizontal) - 1, 0, (h - last) / (first + 360 - last)
+}
+
+// foldHorizontal maps a horizontal angle into the measured range, using the
+// symmetry implied by the first and last horizontal angles. It also reports
+// whether the profile has no symmetry and covers the full circle.
+func (p *IESProfile) foldHorizontal(h float64) (float64, bool) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+
+	first, last := p.horizontal[0], p.horizontal[len(p.horizontal)-1]
+	switch {
+	case len(p.horizontal) == 1:
+		// Rotationally symmetric
+		return p.horizontal[0], false
+	case last == 90:
+		// Symmetric in each quadrant
+		if h > 180 {
+			h = 360 - h
+		}
+		if h > 90 {
+			h = 180 - h
+		}
+	case last == 180:
+		// Symmetric about the 0-180 degree plane
+		if h > 180 {
+			h = 360 - h
+		}
+	case first == 90 && last == 270:
+		// Symmetric about the 90-270 degree plane
+		if h < 90 {
+			h = 180 - h
+		} else if h > 270 {
+			h = 540 - h
+		}
+	default:
+		return h, true
+	}
+	return h, false
+}
+
+// bracket finds the pair of indices in the ascending slice angles surrounding
+// x, and how far between them x lies.
+func bracket(angles []float64, x float64) (int, int, float64) {
+	if len(angles) == 1 || x <= angles[0] {
+		return 0, 0, 0
+	}
+	i := sort.SearchFloat64s(angles, x)
+	if i >= len(angles) {
+		last := len(angles) - 1
+		return last, last, 0
+	}
+	if angles[i] == x {
+		return i, i, 0
+	}
+	return i - 1, i, (x - angles[i-1]) / (angles[i] - angles[i-1])
+}
+
+// iesParser consumes numbers from the body of an IES file, remembering the
+// first error so callers can check once.
+type iesParser struct {
+	numbers []float64
+	pos     int
+	err     error
+}
+
+func (p *iesParser) next() float64 {
+	if p.pos >= len(p.numbers) {
+		if p.err == nil {
+			p.err = fmt.Errorf("unexpected end of data")
+		}
+		return 0
+	}
+	n := p.numbers[p.pos]
+	p.pos++
+	return n
+}
+
+func (p *iesParser) nextInt() int {
+	return int(p.next())
+}
+
+func (p *iesParser) nextN(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = p.next()
+	}
+	return values
+}
+
+func (p *iesParser) skip(n int) {
+	for i := 0; i < n; i++ {
+		p.next()
+	}
+}
@@ -0,0 +1,67 @@
+package light
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// iesWithHorizontal returns a profile measured at the given horizontal angles,
+// whose candela value at each measured angle is the angle itself.
+func iesWithHorizontal(t *testing.T, horizontal ...float64) *IESProfile {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintln(&b, "IESNA:LM-63-2002")
+	fmt.Fprintln(&b, "TILT=NONE")
+	fmt.Fprintf(&b, "1 1000 1 2 %d 1 2 0 0 0\n", len(horizontal))
+	fmt.Fprintln(&b, "1 1 100")
+	fmt.Fprintln(&b, "0 90")
+	for _, h := range horizontal {
+		fmt.Fprint(&b, h, " ")
+	}
+	fmt.Fprintln(&b)
+	for _, h := range horizontal {
+		fmt.Fprintln(&b, h, h)
+	}
+
+	p, err := ParseIES(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("ParseIES: %v", err)
+	}
+	return p
+}
+
+func TestIESHorizontalSymmetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		horizontal []float64
+		h          float64
+		want       float64
+	}{
+		{"rotational", []float64{0}, 123, 0},
+		{"quadrant", []float64{0, 45, 90}, 30, 30},
+		{"quadrant mirrored", []float64{0, 45, 90}, 150, 30},
+		{"quadrant opposite", []float64{0, 45, 90}, 210, 30},
+		{"quadrant last", []float64{0, 45, 90}, 330, 30},
+		{"bilateral 0-180", []float64{0, 90, 180}, 135, 135},
+		{"bilateral 0-180 mirrored", []float64{0, 90, 180}, 225, 135},
+		{"bilateral 90-270", []float64{90, 180, 270}, 200, 200},
+		{"bilateral 90-270 low", []float64{90, 180, 270}, 30, 150},
+		{"bilateral 90-270 high", []float64{90, 180, 270}, 300, 240},
+		{"bilateral 90-270 zero", []float64{90, 180, 270}, 0, 180},
+		{"full", []float64{0, 90, 180, 270, 360}, 300, 300},
+		{"full negative", []float64{0, 90, 180, 270, 360}, -60, 300},
+		{"full without 360", []float64{0, 90, 180, 270}, 270, 270},
+		{"full without 360 gap", []float64{0, 90, 180, 270}, 315, 135},
+		{"full without 360 gap negative", []float64{0, 90, 180, 270}, -45, 135},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := iesWithHorizontal(t, tt.horizontal...)
+			if got := p.Candela(0, tt.h); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Candela(0, %v) = %v, want %v", tt.h, got, tt.want)
+			}
+		})
+	}
+}
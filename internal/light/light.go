@@ -0,0 +1,208 @@
+package light
+
+import (
+	"math"
+	"raytracer/internal/color"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+)
+
+// Light is a light source that is not part of the scene geometry. Rays never
+// hit these lights; instead they are sampled with shadow rays from each shading
+// point.
+type Light interface {
+	// Sample returns the unit direction from p towards the light, the distance
+	// to the light along it, and the radiance arriving at p from that direction
+	// if nothing blocks the way.
+	Sample(p vector.Point3, rng util.RNG) (wi vector.Vec3, dist float64, li color.Color)
+}
+
+// Falloff controls how a light's intensity decreases with distance.
+type Falloff int
+
+const (
+	FalloffInverseSquare Falloff = iota // Physically based 1/d^2
+	FalloffLinear                       // 1/d, a softer artistic falloff
+	FalloffNone                         // Constant with distance
+)
+
+func (f Falloff) attenuate(dist float64) float64 {
+	switch f {
+	case FalloffLinear:
+		return 1 / dist
+	case FalloffNone:
+		return 1
+	default:
+		return 1 / (dist * dist)
+	}
+}
+
+// PointLight emits light from a single point, equally in all directions unless
+// it has a photometric profile.
+type PointLight struct {
+	position  vector.Point3
+	intensity color.Color
+	falloff   Falloff
+	profile   *orientedProfile
+}
+
+func NewPointLight(position vector.Point3, intensity color.Color, falloff Falloff) PointLight {
+	return PointLight{position: position, intensity: intensity, falloff: falloff}
+}
+
+// WithProfile returns a copy of the light whose emission is modulated by an IES
+// profile, with the profile's nadir pointing along axis and its 0 degree
+// horizontal angle towards reference. The light's intensity is emitted in the
+// profile's brightest direction.
+func (l PointLight) WithProfile(profile *IESProfile, axis, reference vector.Vec3) PointLight {
+	l.profile = newOrientedProfile(profile, axis, reference)
+	return l
+}
+
+func (l PointLight) Sample(p vector.Point3, rng util.RNG) (vector.Vec3, float64, color.Color) {
+	toLight := l.position.Sub(p)
+	dist := toLight.Length()
+	wi := toLight.Div(dist)
+	return wi, dist, l.intensity.Scale(l.falloff.attenuate(dist) * l.profile.scale(wi.Neg()))
+}
+
+// SpotLight emits light from a point within a cone. Its intensity fades to
+// zero across a soft edge at the cone's border.
+type SpotLight struct {
+	position  vector.Point3
+	direction vector.Vec3 // Unit vector along the cone's axis
+	intensity color.Color
+	cosOuter  float64 // Cosine of the angle where the light is fully off
+	cosInner  float64 // Cosine of the angle where the light is fully on
+	falloff   Falloff
+	profile   *orientedProfile
+}
+
+// NewSpotLight creates a spot light. coneAngle is the angle in degrees between
+// the axis and the edge of the cone, and softEdge is how many degrees inside
+// that edge the light starts to fade.
+func NewSpotLight(position vector.Point3, direction vector.Vec3, intensity color.Color, coneAngle, softEdge float64, falloff Falloff) SpotLight {
+	outer := util.DegreesToRadians(coneAngle)
+	inner := math.Max(0, outer-util.DegreesToRadians(softEdge))
+	return SpotLight{
+		position:  position,
+		direction: direction.Unit(),
+		intensity: intensity,
+		cosOuter:  math.Cos(outer),
+		cosInner:  math.Cos(inner),
+		falloff:   falloff,
+	}
+}
+
+// WithProfile returns a copy of the light whose emission is modulated by an IES
+// profile, with the profile's nadir along the spot's axis and its 0 degree
+// horizontal angle towards reference. The cone still limits where light is
+// emitted.
+func (l SpotLight) WithProfile(profile *IESProfile, reference vector.Vec3) SpotLight {
+	l.profile = newOrientedProfile(profile, l.direction, reference)
+	return l
+}
+
+func (l SpotLight) Sample(p vector.Point3, rng util.RNG) (vector.Vec3, float64, color.Color) {
+	toLight := l.position.Sub(p)
+	dist := toLight.Length()
+	wi := toLight.Div(dist)
+
+	cosTheta := vector.Dot(wi.Neg(), l.direction)
+	scale := smoothStep(l.cosOuter, l.cosInner, cosTheta) * l.falloff.attenuate(dist) * l.profile.scale(wi.Neg())
+	return wi, dist, l.intensity.Scale(scale)
+}
+
+// DirectionalLight is a distant light such as the sun, arriving from the same
+// direction everywhere in the scene. A non-zero angular diameter spreads the
+// light over a small cone to produce soft shadows.
+type DirectionalLight struct {
+	direction   vector.Vec3 // Unit vector the light travels along
+	irradiance  color.Color
+	cosMaxAngle float64
+}
+
+// NewDirectionalLight creates a directional light. angularDiameter is the
+// apparent size of the light source in degrees; the sun is about 0.53.
+func NewDirectionalLight(direction vector.Vec3, irradiance color.Color, angularDiameter float64) DirectionalLight {
+	return DirectionalLight{
+		direction:   direction.Unit(),
+		irradiance:  irradiance,
+		cosMaxAngle: math.Cos(util.DegreesToRadians(angularDiameter / 2)),
+	}
+}
+
+func (l DirectionalLight) Sample(p vector.Point3, rng util.RNG) (vector.Vec3, float64, color.Color) {
+	wi := l.direction.Neg()
+	if l.cosMaxAngle < 1 {
+		// Sample uniformly within the cone subtended by the light.
+		cosTheta := 1 - util.RandomFloat(rng)*(1-l.cosMaxAngle)
+		sinTheta := math.Sqrt(1 - cosTheta*cosTheta)
+		phi := 2 * math.Pi * util.RandomFloat(rng)
+		u, v := orthonormalBasis(wi)
+		wi = wi.Scale(cosTheta).
+			Add(u.Scale(sinTheta * math.Cos(phi))).
+			Add(v.Scale(sinTheta * math.Sin(phi)))
+	}
+	return wi, math.Inf(1), l.irradiance
+}
+
+// orientedProfile places an IES profile in the scene. Vertical angles are
+// measured from the nadir axis, and horizontal angles around it from u, the 0
+// degree direction, towards v, the 90 degree direction, which is nadir x u.
+type orientedProfile struct {
+	profile *IESProfile
+	nadir   vector.Vec3
+	u, v    vector.Vec3
+}
+
+// newOrientedProfile orients profile with its 0 degree horizontal angle along
+// reference projected perpendicular to nadir. If reference is parallel to
+// nadir, an arbitrary perpendicular direction is used instead.
+func newOrientedProfile(profile *IESProfile, nadir, reference vector.Vec3) *orientedProfile {
+	if profile == nil {
+		return nil
+	}
+	nadir = nadir.Unit()
+	u := reference.Sub(nadir.Scale(vector.Dot(reference, nadir)))
+	if u.Length() < 1e-8 {
+		u, _ = orthonormalBasis(nadir)
+	}
+	u = u.Unit()
+	return &orientedProfile{profile, nadir, u, vector.Cross(nadir, u)}
+}
+
+// scale returns the relative emission in the unit direction dir. A missing
+// profile emits equally everywhere.
+func (o *orientedProfile) scale(dir vector.Vec3) float64 {
+	if o == nil {
+		return 1
+	}
+	cosTheta := math.Max(-1, math.Min(1, vector.Dot(dir, o.nadir)))
+	vertical := math.Acos(cosTheta) * 180 / math.Pi
+	horizontal := math.Atan2(vector.Dot(dir, o.v), vector.Dot(dir, o.u)) * 180 / math.Pi
+	return o.profile.Normalized(vertical, horizontal)
+}
+
+// smoothStep is 0 below edge0, 1 above edge1, and eases smoothly in between.
+func smoothStep(edge0, edge1, x float64) float64 {
+	if edge1 <= edge0 {
+		if x >= edge0 {
+			return 1
+		}
+		return 0
+	}
+	t := math.Max(0, math.Min(1, (x-edge0)/(edge1-edge0)))
+	return t * t * (3 - 2*t)
+}
+
+// orthonormalBasis returns two unit vectors perpendicular to w and each other.
+func orthonormalBasis(w vector.Vec3) (vector.Vec3, vector.Vec3) {
+	a := vector.NewVec3(1, 0, 0)
+	if math.Abs(w.X()) > 0.9 {
+		a = vector.NewVec3(0, 1, 0)
+	}
+	v := vector.Cross(w, a).Unit()
+	u := vector.Cross(v, w)
+	return u, v
+}
@@ -0,0 +1,42 @@
+package material
+
+import (
+	"raytracer/internal/color"
+	"raytracer/internal/core"
+	"raytracer/internal/ray"
+	"raytracer/internal/texture"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+)
+
+// AlphaMask cuts holes in a base material using an opacity texture, so that a
+// single quad can stand in for a leaf or a fence. Partially opaque points are
+// hit with a probability equal to their opacity. It must be the outermost
+// material on an object for the mask to be seen.
+type AlphaMask struct {
+	base  core.Material
+	alpha texture.Texture
+}
+
+func NewAlphaMask(base core.Material, alpha texture.Texture) AlphaMask {
+	return AlphaMask{base, alpha}
+}
+
+func (am AlphaMask) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	return am.base.Scatter(rIn, rec, attenuation, scattered, rng)
+}
+
+func (am AlphaMask) Eval(rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	return evalMaterial(am.base, rIn, rec, wi)
+}
+
+func (am AlphaMask) Emitted(rec *core.HitRecord) color.Color {
+	return emittedBy(am.base, rec)
+}
+
+// Opacity returns the average of the alpha texture's channels at the hit point.
+func (am AlphaMask) Opacity(rec *core.HitRecord) float64 {
+	u, v := rec.UV()
+	c := am.alpha.Value(u, v, rec.Point())
+	return (c.X() + c.Y() + c.Z()) / 3
+}
@@ -17,7 +17,7 @@ func NewDielectric(ri float64) Dielectric {
 	return Dielectric{ri}
 }
 
-func (d Dielectric) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray) bool {
+func (d Dielectric) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
 	*attenuation = color.NewColor(1, 1, 1)
 
 	ri := d.refractionIndex
@@ -32,13 +32,13 @@ func (d Dielectric) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color
 	cannotRefract := ri*sinTheta > 1.0
 	var direction vector.Vec3
 
-	if cannotRefract || reflectance(cosTheta, ri) > util.RandomFloat() {
+	if cannotRefract || reflectance(cosTheta, ri) > util.RandomFloat(rng) {
 		direction = vector.Reflect(unitDirection, rec.Normal())
 	} else {
 		direction = vector.Refract(unitDirection, rec.Normal(), ri)
 	}
 
-	*scattered = ray.NewRay(rec.Point(), direction)
+	*scattered = ray.NewRayWithTime(rec.Point(), direction, rIn.Time())
 
 	return true
 }
@@ -0,0 +1,37 @@
+package material
+
+import (
+	"raytracer/internal/color"
+	"raytracer/internal/core"
+	"raytracer/internal/ray"
+	"raytracer/internal/texture"
+	"raytracer/internal/util"
+)
+
+// DiffuseLight is an emissive material whose radiance may vary across the
+// surface, such as a TV screen or a stained glass window. It doesn't scatter
+// incoming light.
+type DiffuseLight struct {
+	tex      texture.Texture
+	twoSided bool // Whether the back face emits as well as the front
+}
+
+func NewDiffuseLight(emit color.Color, twoSided bool) DiffuseLight {
+	return NewDiffuseLightTexture(texture.NewSolidColor(emit), twoSided)
+}
+
+func NewDiffuseLightTexture(tex texture.Texture, twoSided bool) DiffuseLight {
+	return DiffuseLight{tex, twoSided}
+}
+
+func (d DiffuseLight) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	return false
+}
+
+func (d DiffuseLight) Emitted(rec *core.HitRecord) color.Color {
+	if !d.twoSided && !rec.FrontFace() {
+		return color.NewColor(0, 0, 0)
+	}
+	u, v := rec.UV()
+	return d.tex.Value(u, v, rec.Point())
+}
@@ -1,9 +1,11 @@
 package material
 
 import (
+	"math"
 	"raytracer/internal/color"
 	"raytracer/internal/core"
 	"raytracer/internal/ray"
+	"raytracer/internal/util"
 	"raytracer/internal/vector"
 )
 
@@ -15,15 +17,23 @@ func NewLambertian(albedo color.Color) Lambertian {
 	return Lambertian{albedo}
 }
 
-func (l Lambertian) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray) bool {
-	scatterDirection := rec.Normal().Add(vector.RandomUnitVector())
+func (l Lambertian) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	scatterDirection := rec.Normal().Add(vector.RandomUnitVector(rng))
 
 	// Catch degenerate scatter direction
 	if scatterDirection.NearZero() {
 		scatterDirection = rec.Normal()
 	}
 
-	*scattered = ray.NewRay(rec.Point(), scatterDirection)
+	*scattered = ray.NewRayWithTime(rec.Point(), scatterDirection, rIn.Time())
 	*attenuation = l.albedo
 	return true
 }
+
+func (l Lambertian) Eval(rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	cosTheta := vector.Dot(wi, rec.Normal())
+	if cosTheta <= 0 {
+		return color.NewColor(0, 0, 0)
+	}
+	return l.albedo.Scale(cosTheta / math.Pi)
+}
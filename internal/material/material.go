@@ -0,0 +1,25 @@
+package material
+
+import (
+	"raytracer/internal/color"
+	"raytracer/internal/core"
+	"raytracer/internal/ray"
+	"raytracer/internal/vector"
+)
+
+// emittedBy returns the light emitted by mat at the hit point, if any.
+func emittedBy(mat core.Material, rec *core.HitRecord) color.Color {
+	if emitter, ok := mat.(core.Emitter); ok {
+		return emitter.Emitted(rec)
+	}
+	return color.NewColor(0, 0, 0)
+}
+
+// evalMaterial evaluates mat for light arriving from wi, treating materials
+// that can't be evaluated as reflecting nothing.
+func evalMaterial(mat core.Material, rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	if bsdf, ok := mat.(core.BSDF); ok {
+		return bsdf.Eval(rIn, rec, wi)
+	}
+	return color.NewColor(0, 0, 0)
+}
@@ -5,6 +5,7 @@ import (
 	"raytracer/internal/color"
 	"raytracer/internal/core"
 	"raytracer/internal/ray"
+	"raytracer/internal/util"
 	"raytracer/internal/vector"
 )
 
@@ -18,10 +19,10 @@ func NewMetal(albedo color.Color, fuzz float64) Metal {
 	return Metal{albedo, fuzz}
 }
 
-func (m Metal) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray) bool {
+func (m Metal) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
 	reflected := vector.Reflect(rIn.Direction(), rec.Normal())
-	reflected = reflected.Unit().Add(vector.RandomUnitVector().Scale(m.fuzz))
-	*scattered = ray.NewRay(rec.Point(), reflected)
+	reflected = reflected.Unit().Add(vector.RandomUnitVector(rng).Scale(m.fuzz))
+	*scattered = ray.NewRayWithTime(rec.Point(), reflected, rIn.Time())
 	*attenuation = m.albedo
 	return vector.Dot(scattered.Direction(), rec.Normal()) > 0
 }
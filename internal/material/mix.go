@@ -0,0 +1,114 @@
+package material
+
+import (
+	"math"
+	"raytracer/internal/color"
+	"raytracer/internal/core"
+	"raytracer/internal/ray"
+	"raytracer/internal/texture"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+)
+
+// Mix blends two materials, e.g. 70% metal and 30% diffuse. Each scattering
+// event picks one of the two materials at random according to the weight.
+type Mix struct {
+	first, second core.Material
+	weight        texture.Texture // Fraction of the second material
+}
+
+// NewMix blends two materials with a constant weight in [0,1], where 0 is all
+// first and 1 is all second.
+func NewMix(first, second core.Material, weight float64) Mix {
+	return NewMixTexture(first, second, texture.NewSolidColor(color.NewColor(weight, weight, weight)))
+}
+
+// NewMixTexture blends two materials by the average of a texture's channels.
+func NewMixTexture(first, second core.Material, weight texture.Texture) Mix {
+	return Mix{first, second, weight}
+}
+
+func (m Mix) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	u, v := rec.UV()
+	w := m.weight.Value(u, v, rec.Point())
+	if util.RandomFloat(rng) < (w.X()+w.Y()+w.Z())/3 {
+		return m.second.Scatter(rIn, rec, attenuation, scattered, rng)
+	}
+	return m.first.Scatter(rIn, rec, attenuation, scattered, rng)
+}
+
+func (m Mix) Eval(rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	u, v := rec.UV()
+	w := m.weight.Value(u, v, rec.Point())
+	weight := (w.X() + w.Y() + w.Z()) / 3
+	return evalMaterial(m.first, rIn, rec, wi).Scale(1 - weight).
+		Add(evalMaterial(m.second, rIn, rec, wi).Scale(weight))
+}
+
+// Coated layers a smooth dielectric clear coat over a base material, like
+// varnish on wood or the lacquer on car paint. Light is either reflected off
+// the coat according to Fresnel, or refracted through it to the base and back
+// out again.
+type Coated struct {
+	base            core.Material
+	refractionIndex float64
+}
+
+func NewCoated(base core.Material, refractionIndex float64) Coated {
+	return Coated{base, refractionIndex}
+}
+
+func (c Coated) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	// The coat is only on the outside of the surface.
+	if !rec.FrontFace() {
+		return c.base.Scatter(rIn, rec, attenuation, scattered, rng)
+	}
+
+	unitDirection := rIn.Direction().Unit()
+	cosTheta := math.Min(vector.Dot(unitDirection.Scale(-1.0), rec.Normal()), 1.0)
+
+	if reflectance(cosTheta, 1.0/c.refractionIndex) > util.RandomFloat(rng) {
+		*attenuation = color.NewColor(1, 1, 1)
+		*scattered = ray.NewRayWithTime(rec.Point(), vector.Reflect(unitDirection, rec.Normal()), rIn.Time())
+		return true
+	}
+
+	// Scatter off the base as seen from inside the coat.
+	refracted := ray.NewRayWithTime(rIn.Origin(), vector.Refract(unitDirection, rec.Normal(), 1.0/c.refractionIndex), rIn.Time())
+	var baseScattered ray.Ray
+	if !c.base.Scatter(refracted, rec, attenuation, &baseScattered, rng) {
+		return false
+	}
+
+	// Leave the coat, losing whatever is reflected back inside on the way out.
+	outDirection := baseScattered.Direction().Unit()
+	cosOut := vector.Dot(outDirection, rec.Normal())
+	if cosOut <= 0 {
+		return false
+	}
+	sinOut := math.Sqrt(1.0 - cosOut*cosOut)
+	if c.refractionIndex*sinOut > 1.0 {
+		return false
+	}
+
+	*attenuation = attenuation.Scale(1 - reflectance(cosOut, c.refractionIndex))
+	*scattered = ray.NewRayWithTime(baseScattered.Origin(), vector.Refract(outDirection, rec.Normal().Neg(), c.refractionIndex), baseScattered.Time())
+	return true
+}
+
+// Eval approximates the light reaching the base through the coat by weighting
+// the base's response with the Fresnel transmittance on the way in and out.
+func (c Coated) Eval(rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	if !rec.FrontFace() {
+		return evalMaterial(c.base, rIn, rec, wi)
+	}
+
+	cosIn := math.Min(vector.Dot(rIn.Direction().Unit().Neg(), rec.Normal()), 1.0)
+	cosOut := math.Min(vector.Dot(wi, rec.Normal()), 1.0)
+	if cosOut <= 0 {
+		return color.NewColor(0, 0, 0)
+	}
+
+	transmittance := (1 - reflectance(cosIn, 1.0/c.refractionIndex)) * (1 - reflectance(cosOut, 1.0/c.refractionIndex))
+	return evalMaterial(c.base, rIn, rec, wi).Scale(transmittance)
+}
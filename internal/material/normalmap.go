@@ -0,0 +1,128 @@
+package material
+
+import (
+	"raytracer/internal/color"
+	"raytracer/internal/core"
+	"raytracer/internal/ray"
+	"raytracer/internal/texture"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+)
+
+// NormalMap perturbs the shading normal of a base material using a tangent
+// space normal map, where the red, green and blue channels hold the normal's
+// components along dpdu, dpdv and the surface normal.
+type NormalMap struct {
+	base      core.Material
+	normalMap texture.Texture
+}
+
+func NewNormalMap(base core.Material, normalMap texture.Texture) NormalMap {
+	return NormalMap{base, normalMap}
+}
+
+func (nm NormalMap) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	perturbed := nm.perturb(rec)
+	return nm.base.Scatter(rIn, &perturbed, attenuation, scattered, rng)
+}
+
+func (nm NormalMap) Eval(rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	perturbed := nm.perturb(rec)
+	return evalMaterial(nm.base, rIn, &perturbed, wi)
+}
+
+// perturb returns a copy of rec with the normal taken from the normal map.
+func (nm NormalMap) perturb(rec *core.HitRecord) core.HitRecord {
+	perturbed := *rec
+	n := rec.Normal()
+	t, b, ok := tangentFrame(rec)
+	if !ok {
+		return perturbed
+	}
+
+	// Remap from [0,1] to [-1,1].
+	u, v := rec.UV()
+	m := nm.normalMap.Value(u, v, rec.Point()).Scale(2).Sub(color.NewColor(1, 1, 1))
+
+	perturbed.SetNormal(t.Scale(m.X()).Add(b.Scale(m.Y())).Add(n.Scale(m.Z())).Unit())
+	return perturbed
+}
+
+// BumpMap perturbs the shading normal of a base material as if the surface were
+// displaced along its normal by a height texture.
+type BumpMap struct {
+	base   core.Material
+	height texture.Texture
+	scale  float64 // Displacement for a height value of 1
+}
+
+func NewBumpMap(base core.Material, height texture.Texture, scale float64) BumpMap {
+	return BumpMap{base, height, scale}
+}
+
+func (bm BumpMap) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	perturbed := bm.perturb(rec)
+	return bm.base.Scatter(rIn, &perturbed, attenuation, scattered, rng)
+}
+
+func (bm BumpMap) Eval(rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	perturbed := bm.perturb(rec)
+	return evalMaterial(bm.base, rIn, &perturbed, wi)
+}
+
+// perturb returns a copy of rec with the normal bent by the height gradient.
+func (bm BumpMap) perturb(rec *core.HitRecord) core.HitRecord {
+	perturbed := *rec
+	dpdu, dpdv := rec.Dpdu(), rec.Dpdv()
+	if dpdu.NearZero() || dpdv.NearZero() {
+		return perturbed
+	}
+
+	// Estimate the height gradient with forward differences.
+	const delta = 0.0005
+	u, v := rec.UV()
+	p := rec.Point()
+	h := bm.heightAt(u, v, p)
+	dhdu := (bm.heightAt(u+delta, v, p) - h) / delta
+	dhdv := (bm.heightAt(u, v+delta, p) - h) / delta
+
+	// Displace along the outward normal so bumps point out of the object
+	// regardless of which side was hit.
+	outward := rec.Normal()
+	if !rec.FrontFace() {
+		outward = outward.Neg()
+	}
+	displacedU := dpdu.Add(outward.Scale(dhdu * bm.scale))
+	displacedV := dpdv.Add(outward.Scale(dhdv * bm.scale))
+
+	n := vector.Cross(displacedU, displacedV).Unit()
+	if vector.Dot(n, rec.Normal()) < 0 {
+		n = n.Neg()
+	}
+
+	perturbed.SetNormal(n)
+	return perturbed
+}
+
+// heightAt returns the height texture's value as the average of its channels.
+func (bm BumpMap) heightAt(u, v float64, p vector.Point3) float64 {
+	c := bm.height.Value(u, v, p)
+	return (c.X() + c.Y() + c.Z()) / 3
+}
+
+// tangentFrame builds an orthonormal tangent and bitangent around the shading
+// normal from the hit's surface derivatives.
+func tangentFrame(rec *core.HitRecord) (vector.Vec3, vector.Vec3, bool) {
+	n := rec.Normal()
+	t := rec.Dpdu().Sub(n.Scale(vector.Dot(rec.Dpdu(), n)))
+	if t.NearZero() {
+		return vector.Vec3{}, vector.Vec3{}, false
+	}
+	t = t.Unit()
+
+	b := vector.Cross(n, t)
+	if vector.Dot(b, rec.Dpdv()) < 0 {
+		b = b.Neg()
+	}
+	return t, b, true
+}
@@ -0,0 +1,100 @@
+package material
+
+import (
+	"math"
+	"raytracer/internal/color"
+	"raytracer/internal/core"
+	"raytracer/internal/ray"
+	"raytracer/internal/texture"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+)
+
+// OrenNayar is a rough diffuse material, modeling the surface as a collection
+// of tiny Lambertian facets. It brightens towards the light at grazing angles,
+// which suits clay, concrete and cloth. With zero roughness it is equivalent to
+// Lambertian.
+// https://en.wikipedia.org/wiki/Oren%E2%80%93Nayar_reflectance_model
+type OrenNayar struct {
+	tex  texture.Texture
+	a, b float64
+}
+
+// NewOrenNayar creates an Oren-Nayar material with a solid albedo. sigma is the
+// standard deviation of the facet slope angle, in degrees.
+func NewOrenNayar(albedo color.Color, sigma float64) OrenNayar {
+	return NewOrenNayarTexture(texture.NewSolidColor(albedo), sigma)
+}
+
+func NewOrenNayarTexture(tex texture.Texture, sigma float64) OrenNayar {
+	s := util.DegreesToRadians(math.Max(0, sigma))
+	s2 := s * s
+	a := 1 - s2/(2*(s2+0.33))
+	b := 0.45 * s2 / (s2 + 0.09)
+	return OrenNayar{tex, a, b}
+}
+
+func (o OrenNayar) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	normal := rec.Normal()
+	scatterDirection := normal.Add(vector.RandomUnitVector(rng))
+
+	// Catch degenerate scatter direction
+	if scatterDirection.NearZero() {
+		scatterDirection = normal
+	}
+
+	*scattered = ray.NewRayWithTime(rec.Point(), scatterDirection, rIn.Time())
+
+	// Directions are sampled proportional to cosine like Lambertian, so the
+	// sample weight is the albedo scaled by the Oren-Nayar factor.
+	u, v := rec.UV()
+	albedo := o.tex.Value(u, v, rec.Point())
+	*attenuation = albedo.Scale(o.factor(rIn.Direction().Unit().Neg(), scatterDirection.Unit(), normal))
+	return true
+}
+
+func (o OrenNayar) Eval(rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	normal := rec.Normal()
+	cosTheta := vector.Dot(wi, normal)
+	if cosTheta <= 0 {
+		return color.NewColor(0, 0, 0)
+	}
+
+	u, v := rec.UV()
+	albedo := o.tex.Value(u, v, rec.Point())
+	return albedo.Scale(o.factor(rIn.Direction().Unit().Neg(), wi, normal) * cosTheta / math.Pi)
+}
+
+// factor returns A + B max(0, cos(phiI - phiO)) sin(alpha) tan(beta) for the
+// incoming direction wi and outgoing direction wo.
+func (o OrenNayar) factor(wi, wo, normal vector.Vec3) float64 {
+	if o.b == 0 {
+		return o.a
+	}
+
+	cosI := vector.Dot(wi, normal)
+	cosO := vector.Dot(wo, normal)
+	sinI := math.Sqrt(math.Max(0, 1-cosI*cosI))
+	sinO := math.Sqrt(math.Max(0, 1-cosO*cosO))
+
+	// Cosine of the azimuthal angle between the two directions, found by
+	// projecting them onto the tangent plane.
+	maxCos := 0.0
+	if sinI > 1e-4 && sinO > 1e-4 {
+		pi := wi.Sub(normal.Scale(cosI)).Div(sinI)
+		po := wo.Sub(normal.Scale(cosO)).Div(sinO)
+		maxCos = math.Max(0, vector.Dot(pi, po))
+	}
+
+	// alpha is the larger of the two angles, beta the smaller.
+	var sinAlpha, tanBeta float64
+	if math.Abs(cosI) > math.Abs(cosO) {
+		sinAlpha = sinO
+		tanBeta = sinI / math.Abs(cosI)
+	} else {
+		sinAlpha = sinI
+		tanBeta = sinO / math.Max(math.Abs(cosO), 1e-8)
+	}
+
+	return o.a + o.b*maxCos*sinAlpha*tanBeta
+}
@@ -0,0 +1,117 @@
+package material
+
+import (
+	"math"
+	"raytracer/internal/color"
+	"raytracer/internal/core"
+	"raytracer/internal/ray"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+)
+
+// Subsurface is a translucent material such as skin, wax, milk or marble. Light
+// refracts into the object and performs a random walk through its volume,
+// scattering until it leaves again. The object must be closed.
+//
+// The walk is carried out one segment per bounce: a ray traveling inside the
+// object will next hit the inside of its boundary, and at that point the
+// material decides whether the ray scattered somewhere along the way or made it
+// out. Each step of the walk therefore counts towards the camera's MaxDepth.
+type Subsurface struct {
+	albedo          color.Color // Single scattering albedo per channel
+	sigmaT          color.Color // Extinction coefficient per channel
+	refractionIndex float64
+	diffuse         color.Color // Total diffuse reflectance of the medium, for Eval
+}
+
+// NewSubsurface creates a subsurface scattering material. meanFreePath is the
+// average distance light travels inside the medium between scattering events,
+// per color channel, in scene units.
+func NewSubsurface(albedo, meanFreePath color.Color, refractionIndex float64) Subsurface {
+	var sigmaT, diffuse color.Color
+	for i := 0; i < 3; i++ {
+		sigmaT.Set(i, 1/math.Max(meanFreePath.At(i), 1e-8))
+		diffuse.Set(i, diffuseReflectance(albedo.At(i), refractionIndex))
+	}
+	return Subsurface{albedo, sigmaT, refractionIndex, diffuse}
+}
+
+// diffuseReflectance returns the fraction of light entering a semi-infinite
+// medium with the given single scattering albedo that eventually leaves it
+// again, from the dipole diffusion approximation (Jensen et al. 2001).
+func diffuseReflectance(albedo, refractionIndex float64) float64 {
+	eta := refractionIndex
+	fdr := -1.440/(eta*eta) + 0.710/eta + 0.668 + 0.0636*eta
+	a := (1 + fdr) / (1 - fdr)
+	s := math.Sqrt(3 * (1 - math.Min(albedo, 1)))
+	return albedo / 2 * (1 + math.Exp(-4.0/3.0*a*s)) * math.Exp(-s)
+}
+
+func (s Subsurface) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	unitDirection := rIn.Direction().Unit()
+
+	if rec.FrontFace() {
+		// Entering the object: reflect or refract at the surface like a dielectric.
+		*attenuation = color.NewColor(1, 1, 1)
+		*scattered = ray.NewRayWithTime(rec.Point(), s.interfaceDirection(unitDirection, rec.Normal(), 1.0/s.refractionIndex, rng), rIn.Time())
+		return true
+	}
+
+	// Traveling inside the object, from the previous event to the boundary.
+	distance := rec.T() * rIn.Direction().Length()
+
+	// Sample a free flight distance using a randomly chosen channel's
+	// extinction. The sample is weighted by the average of the three channels'
+	// densities, so that each channel remains unbiased.
+	channel := min(int(util.RandomFloat(rng)*3), 2)
+	d := -math.Log(1-util.RandomFloat(rng)) / s.sigmaT.At(channel)
+
+	var transmittance color.Color
+	for i := 0; i < 3; i++ {
+		transmittance.Set(i, math.Exp(-s.sigmaT.At(i)*math.Min(d, distance)))
+	}
+
+	if d < distance {
+		// Scattered inside the medium; continue the walk in a random direction.
+		pdf := vector.Dot(s.sigmaT, transmittance) / 3
+		*attenuation = s.albedo.Mul(s.sigmaT).Mul(transmittance).Div(pdf)
+		*scattered = ray.NewRayWithTime(rIn.At(d/rIn.Direction().Length()), vector.RandomUnitVector(rng), rIn.Time())
+		return true
+	}
+
+	// Reached the boundary without scattering, so try to leave the object.
+	pdf := (transmittance.X() + transmittance.Y() + transmittance.Z()) / 3
+	*attenuation = transmittance.Div(pdf)
+	*scattered = ray.NewRayWithTime(rec.Point(), s.interfaceDirection(unitDirection, rec.Normal(), s.refractionIndex, rng), rIn.Time())
+	return true
+}
+
+// Eval approximates the random walk as diffuse reflection: light refracts in
+// from wi, leaves with the medium's total diffuse reflectance, and refracts
+// out again. It only lights the outside of the surface.
+func (s Subsurface) Eval(rIn ray.Ray, rec *core.HitRecord, wi vector.Vec3) color.Color {
+	if !rec.FrontFace() {
+		return color.NewColor(0, 0, 0)
+	}
+
+	cosIn := math.Min(vector.Dot(rIn.Direction().Unit().Neg(), rec.Normal()), 1.0)
+	cosOut := math.Min(vector.Dot(wi, rec.Normal()), 1.0)
+	if cosOut <= 0 {
+		return color.NewColor(0, 0, 0)
+	}
+
+	transmittance := (1 - reflectance(cosIn, 1.0/s.refractionIndex)) * (1 - reflectance(cosOut, 1.0/s.refractionIndex))
+	return s.diffuse.Scale(transmittance * cosOut / math.Pi)
+}
+
+// interfaceDirection picks between reflection and refraction at the surface in
+// proportion to the Fresnel reflectance.
+func (s Subsurface) interfaceDirection(unitDirection, normal vector.Vec3, ri float64, rng util.RNG) vector.Vec3 {
+	cosTheta := math.Min(vector.Dot(unitDirection.Scale(-1.0), normal), 1.0)
+	sinTheta := math.Sqrt(1.0 - cosTheta*cosTheta)
+
+	if ri*sinTheta > 1.0 || reflectance(cosTheta, ri) > util.RandomFloat(rng) {
+		return vector.Reflect(unitDirection, normal)
+	}
+	return vector.Refract(unitDirection, normal, ri)
+}
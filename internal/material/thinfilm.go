@@ -0,0 +1,145 @@
+package material
+
+import (
+	"math"
+	"math/cmplx"
+	"raytracer/internal/color"
+	"raytracer/internal/core"
+	"raytracer/internal/ray"
+	"raytracer/internal/util"
+	"raytracer/internal/vector"
+)
+
+// Representative wavelengths (in nanometers) used to evaluate thin-film
+// interference for the red, green and blue channels.
+var rgbWavelengths = [3]float64{650, 532, 450}
+
+// ThinFilm is a dielectric interface coated with a thin transparent film, such
+// as an anti-reflective lens coating or an oil slick on water. With a substrate
+// index of 1 it models a free-standing film like a soap bubble.
+type ThinFilm struct {
+	thickness    float64 // Film thickness in nanometers
+	filmIOR      float64 // Refractive index of the film
+	substrateIOR float64 // Refractive index of the material under the film
+}
+
+func NewThinFilm(thickness, filmIOR, substrateIOR float64) ThinFilm {
+	return ThinFilm{math.Max(0, thickness), filmIOR, substrateIOR}
+}
+
+func (tf ThinFilm) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	// The film sits on the outside of the surface, so a ray leaving the
+	// substrate passes through it in the opposite order.
+	n1, n3 := 1.0, tf.substrateIOR
+	if !rec.FrontFace() {
+		n1, n3 = tf.substrateIOR, 1.0
+	}
+	ri := n1 / n3
+
+	unitDirection := rIn.Direction().Unit()
+	cosTheta := math.Min(vector.Dot(unitDirection.Scale(-1.0), rec.Normal()), 1.0)
+	sinTheta := math.Sqrt(1.0 - cosTheta*cosTheta)
+
+	reflected := color.NewColor(1, 1, 1)
+	if ri*sinTheta <= 1.0 {
+		reflected = thinFilmReflectance(cosTheta, n1, tf.filmIOR, n3, tf.thickness)
+	}
+
+	// Choose between reflection and transmission in proportion to the average
+	// reflectance, then weight by the per-channel ratio to stay unbiased.
+	p := (reflected.X() + reflected.Y() + reflected.Z()) / 3
+	if util.RandomFloat(rng) < p {
+		*attenuation = reflected.Div(p)
+		*scattered = ray.NewRayWithTime(rec.Point(), vector.Reflect(unitDirection, rec.Normal()), rIn.Time())
+		return true
+	}
+
+	transmitted := color.NewColor(1, 1, 1).Sub(reflected)
+	*attenuation = transmitted.Div(1 - p)
+	*scattered = ray.NewRayWithTime(rec.Point(), vector.Refract(unitDirection, rec.Normal(), ri), rIn.Time())
+	return true
+}
+
+// IridescentMetal is a metal with a thin transparent film on top, producing the
+// colored sheen seen on heat-tinted steel or anodized titanium.
+type IridescentMetal struct {
+	albedo    color.Color
+	fuzz      float64
+	thickness float64 // Film thickness in nanometers
+	filmIOR   float64 // Refractive index of the film
+}
+
+func NewIridescentMetal(albedo color.Color, fuzz, thickness, filmIOR float64) IridescentMetal {
+	return IridescentMetal{albedo, math.Min(fuzz, 1.0), math.Max(0, thickness), filmIOR}
+}
+
+func (m IridescentMetal) Scatter(rIn ray.Ray, rec *core.HitRecord, attenuation *color.Color, scattered *ray.Ray, rng util.RNG) bool {
+	unitDirection := rIn.Direction().Unit()
+	cosTheta := math.Min(vector.Dot(unitDirection.Scale(-1.0), rec.Normal()), 1.0)
+
+	reflected := vector.Reflect(unitDirection, rec.Normal())
+	reflected = reflected.Add(vector.RandomUnitVector(rng).Scale(m.fuzz))
+	*scattered = ray.NewRayWithTime(rec.Point(), reflected, rIn.Time())
+
+	// The metal's albedo is used as its normal-incidence reflectance. Metals
+	// flip the phase of reflected light, so the amplitude is negated.
+	var r color.Color
+	for i, lambda := range rgbWavelengths {
+		r23 := complex(-math.Sqrt(m.albedo.At(i)), 0)
+		r.Set(i, airyReflectance(cosTheta, 1.0, m.filmIOR, lambda, m.thickness, func(complex128) (complex128, complex128) {
+			return r23, r23
+		}))
+	}
+	*attenuation = r
+
+	return vector.Dot(scattered.Direction(), rec.Normal()) > 0
+}
+
+// thinFilmReflectance returns the reflectance for each color channel of a film
+// with index n2 and the given thickness, lying between media n1 and n3.
+func thinFilmReflectance(cosTheta1, n1, n2, n3 float64, thickness float64) color.Color {
+	// Amplitudes at the film/substrate interface, given the cosine inside the film.
+	lower := func(cos2 complex128) (complex128, complex128) {
+		sin2Sq := 1 - cos2*cos2
+		cos3 := cmplx.Sqrt(1 - sin2Sq*complex((n2*n2)/(n3*n3), 0))
+		return fresnelAmplitudes(cos2, cos3, complex(n2, 0), complex(n3, 0))
+	}
+
+	var r color.Color
+	for i, lambda := range rgbWavelengths {
+		r.Set(i, airyReflectance(cosTheta1, n1, n2, lambda, thickness, lower))
+	}
+	return r
+}
+
+// airyReflectance evaluates the Airy summation of all the waves reflected back
+// and forth inside a film of index n2, averaged over s and p polarization. The
+// lower function supplies the reflection amplitudes at the bottom of the film.
+// https://en.wikipedia.org/wiki/Thin-film_interference
+func airyReflectance(cosTheta1, n1, n2, lambda, thickness float64, lower func(cos2 complex128) (complex128, complex128)) float64 {
+	cos1 := complex(cosTheta1, 0)
+	sin1Sq := 1 - cosTheta1*cosTheta1
+	// Snell's law; the cosine goes complex when light is totally reflected.
+	cos2 := cmplx.Sqrt(complex(1-sin1Sq*(n1*n1)/(n2*n2), 0))
+
+	r12s, r12p := fresnelAmplitudes(cos1, cos2, complex(n1, 0), complex(n2, 0))
+	r23s, r23p := lower(cos2)
+
+	// Phase difference accumulated by one round trip through the film.
+	delta := 4 * math.Pi * complex(n2*thickness/lambda, 0) * cos2
+	phase := cmplx.Exp(1i * delta)
+
+	rs := (r12s + r23s*phase) / (1 + r12s*r23s*phase)
+	rp := (r12p + r23p*phase) / (1 + r12p*r23p*phase)
+
+	rsAbs, rpAbs := cmplx.Abs(rs), cmplx.Abs(rp)
+	return math.Min((rsAbs*rsAbs+rpAbs*rpAbs)/2, 1)
+}
+
+// fresnelAmplitudes returns the s and p polarized reflection amplitudes for
+// light crossing from index ni into index nt.
+func fresnelAmplitudes(cosI, cosT, ni, nt complex128) (complex128, complex128) {
+	rs := (ni*cosI - nt*cosT) / (ni*cosI + nt*cosT)
+	rp := (nt*cosI - ni*cosT) / (nt*cosI + ni*cosT)
+	return rs, rp
+}
@@ -5,10 +5,17 @@ import "raytracer/internal/vector"
 type Ray struct {
 	origin    vector.Point3
 	direction vector.Vec3
+	time      float64
 }
 
 func NewRay(origin vector.Point3, diretion vector.Vec3) Ray {
-	return Ray{origin, diretion}
+	return Ray{origin, diretion, 0}
+}
+
+// NewRayWithTime creates a ray at a moment during the camera's exposure, used
+// for motion blur.
+func NewRayWithTime(origin vector.Point3, direction vector.Vec3, time float64) Ray {
+	return Ray{origin, direction, time}
 }
 
 func (r Ray) Origin() vector.Point3 {
@@ -19,6 +26,10 @@ func (r Ray) Direction() vector.Vec3 {
 	return r.direction
 }
 
+func (r Ray) Time() float64 {
+	return r.time
+}
+
 func (r Ray) At(t float64) vector.Point3 {
 	return r.origin.Add(r.direction.Scale(t))
 }
@@ -0,0 +1,199 @@
+package sampler
+
+import (
+	"math"
+	"sync"
+)
+
+// blueNoise uses the same scrambled Sobol sequence in every pixel, and offsets
+// each pixel's values by a blue noise mask. Neighboring pixels then get very
+// different offsets, so the error left in the image is high frequency noise
+// that the eye barely notices, instead of clumps.
+// https://belcour.github.io/blog/research/publication/2019/06/17/sampling-bluenoise.html
+type blueNoise struct {
+	pixelState
+	seed uint64
+}
+
+func (s *blueNoise) StartPixelSample(x, y, index int) {
+	s.start(x, y, index)
+}
+
+func (s *blueNoise) Get1D() float64 {
+	d := s.dimension
+	s.dimension++
+	seed := uint32(s.globalHash(d))
+	index := nestedUniformScramble(uint32(s.index), seed)
+	v := toFloat(nestedUniformScramble(sobol0(index), hashUint32(seed, 1)))
+	return wrap(v + s.maskValue(d))
+}
+
+func (s *blueNoise) Get2D() (float64, float64) {
+	d := s.dimension
+	s.dimension += 2
+	x, y := shuffledScrambledSobol2D(uint32(s.index), uint32(s.globalHash(d)))
+	return wrap(x + s.maskValue(d)), wrap(y + s.maskValue(d+1))
+}
+
+func (s *blueNoise) Float64() float64 {
+	return s.Get1D()
+}
+
+// globalHash is the same for every pixel, so they all share one sequence.
+func (s *blueNoise) globalHash(dimension int) uint64 {
+	return hashUint32Pair(s.seed, dimension)
+}
+
+// maskValue looks up the blue noise mask for this pixel. Each dimension uses
+// the mask shifted by a different amount, so dimensions aren't correlated.
+func (s *blueNoise) maskValue(dimension int) float64 {
+	mask := blueNoiseMask()
+	shift := hashUint32Pair(s.seed^0x5bd1e995, dimension)
+	x := (s.x + int(shift%blueNoiseSize)) % blueNoiseSize
+	y := (s.y + int((shift>>16)%blueNoiseSize)) % blueNoiseSize
+	return mask[y*blueNoiseSize+x]
+}
+
+func hashUint32Pair(seed uint64, dimension int) uint64 {
+	return uint64(hashUint32(uint32(seed)^uint32(seed>>32), uint32(dimension)))
+}
+
+// wrap returns the fractional part of x, for x in [0,2).
+func wrap(x float64) float64 {
+	if x >= 1 {
+		x -= 1
+	}
+	return x
+}
+
+// blueNoiseSize is the width and height of the tiling blue noise mask.
+const blueNoiseSize = 64
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoiseData []float64
+)
+
+// blueNoiseMask returns a tileable blue noise mask of values in [0,1), built
+// on first use with Ulichney's void-and-cluster method.
+func blueNoiseMask() []float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoiseData = voidAndCluster(blueNoiseSize, 1.5)
+	})
+	return blueNoiseData
+}
+
+// voidAndCluster ranks every cell of a size x size toroidal grid so that the
+// cells up to any rank are as evenly spread as possible, and returns the ranks
+// scaled to [0,1).
+func voidAndCluster(size int, sigma float64) []float64 {
+	n := size * size
+
+	// Gaussian energy contributed by a point to each offset, wrapping around.
+	kernel := make([]float64, n)
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			x := float64(min(dx, size-dx))
+			y := float64(min(dy, size-dy))
+			kernel[dy*size+dx] = math.Exp(-(x*x + y*y) / (2 * sigma * sigma))
+		}
+	}
+
+	points := make([]bool, n)
+	energy := make([]float64, n)
+	splat := func(i int, sign float64) {
+		px, py := i%size, i/size
+		for y := 0; y < size; y++ {
+			dy := (y - py + size) % size
+			for x := 0; x < size; x++ {
+				dx := (x - px + size) % size
+				energy[y*size+x] += sign * kernel[dy*size+dx]
+			}
+		}
+	}
+
+	// extreme finds the point (or empty cell) with the highest (or lowest)
+	// energy: the tightest cluster or the largest void.
+	extreme := func(want bool, highest bool) int {
+		best := -1
+		for i := 0; i < n; i++ {
+			if points[i] != want {
+				continue
+			}
+			if best < 0 || (highest && energy[i] > energy[best]) || (!highest && energy[i] < energy[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	// Start from a deterministic scattering of about a tenth of the cells,
+	// then swap points from clusters into voids until it settles. The swaps
+	// are capped in case ties make them cycle.
+	initial := n / 10
+	for k := 0; k < initial; k++ {
+		i := int(hashUint32Pair(0x9e3779b9, k) % uint64(n))
+		for points[i] {
+			i = (i + 1) % n
+		}
+		points[i] = true
+		splat(i, 1)
+	}
+	for swaps := 0; swaps < n; swaps++ {
+		cluster := extreme(true, true)
+		points[cluster] = false
+		splat(cluster, -1)
+		void := extreme(false, false)
+		points[void] = true
+		splat(void, 1)
+		if void == cluster {
+			break
+		}
+	}
+	prototype := append([]bool{}, points...)
+	prototypeEnergy := append([]float64{}, energy...)
+
+	ranks := make([]int, n)
+
+	// Rank the initial points by removing the tightest clusters first.
+	for rank := initial - 1; rank >= 0; rank-- {
+		cluster := extreme(true, true)
+		points[cluster] = false
+		splat(cluster, -1)
+		ranks[cluster] = rank
+	}
+
+	// Fill the largest voids until half the grid is set.
+	copy(points, prototype)
+	copy(energy, prototypeEnergy)
+	rank := initial
+	for ; rank < n/2; rank++ {
+		void := extreme(false, false)
+		points[void] = true
+		splat(void, 1)
+		ranks[void] = rank
+	}
+
+	// Past half, the empty cells are the minority, so measure their energy
+	// instead and fill the tightest clusters of empty cells.
+	for i := range energy {
+		energy[i] = 0
+	}
+	for i := 0; i < n; i++ {
+		if !points[i] {
+			splat(i, 1)
+		}
+	}
+	for ; rank < n; rank++ {
+		cluster := extreme(false, true)
+		points[cluster] = true
+		splat(cluster, -1)
+		ranks[cluster] = rank
+	}
+
+	mask := make([]float64, n)
+	for i, r := range ranks {
+		mask[i] = (float64(r) + 0.5) / float64(n)
+	}
+	return mask
+}
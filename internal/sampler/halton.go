@@ -0,0 +1,66 @@
+package sampler
+
+import (
+	"raytracer/internal/util"
+)
+
+// primes are the bases of the Halton sequence's dimensions.
+var primes = [maxDimensions]int{
+	2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53,
+	59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131,
+	137, 139, 149, 151, 157, 163, 167, 173, 179, 181, 191, 193, 197, 199, 211, 223,
+	227, 229, 233, 239, 241, 251, 257, 263, 269, 271, 277, 281, 283, 293, 307, 311,
+}
+
+// halton generates each dimension with the radical inverse of the sample index
+// in that dimension's prime base. Every pixel scrambles the digits differently,
+// which decorrelates neighboring pixels while keeping the sequence's
+// stratification.
+type halton struct {
+	pixelState
+	seed     uint64
+	fallback *independent
+}
+
+func (s *halton) StartPixelSample(x, y, index int) {
+	s.start(x, y, index)
+	s.fallback.StartPixelSample(x, y, index)
+}
+
+func (s *halton) Get1D() float64 {
+	if s.dimension >= maxDimensions {
+		return s.fallback.Get1D()
+	}
+	d := s.dimension
+	s.dimension++
+	return scrambledRadicalInverse(primes[d], uint64(s.index), s.hash(s.seed, d))
+}
+
+func (s *halton) Get2D() (float64, float64) {
+	return s.Get1D(), s.Get1D()
+}
+
+func (s *halton) Float64() float64 {
+	return s.Get1D()
+}
+
+// scrambledRadicalInverse mirrors the base-b digits of index about the radix
+// point, passing each digit through a random permutation that depends on the
+// digits before it (Owen scrambling). Digits beyond the end of index are
+// scrambled too, so the result stays uniform.
+func scrambledRadicalInverse(base int, index, seed uint64) float64 {
+	b := uint64(base)
+	invBase := 1 / float64(base)
+	invBaseM := 1.0
+	result := 0.0
+	prefix := seed
+	for invBaseM > 1e-16 {
+		digit := index % b
+		index /= b
+		permuted := permutationElement(uint32(digit), uint32(b), uint32(prefix^prefix>>32))
+		prefix = util.Hash(prefix, digit)
+		invBaseM *= invBase
+		result += float64(permuted) * invBaseM
+	}
+	return min(result, 1-1e-16)
+}
@@ -0,0 +1,133 @@
+package sampler
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"raytracer/internal/util"
+)
+
+// Sampler supplies the random numbers for each sample of a pixel, one
+// dimension at a time: the position within the pixel, the position on the
+// lens, the shutter time, and then whatever each bounce needs. Well-designed
+// samplers spread these values more evenly than independent random numbers,
+// so images converge with fewer samples.
+//
+// A Sampler is also a util.RNG, whose Float64 returns the next dimension. A
+// Sampler isn't safe for concurrent use.
+type Sampler interface {
+	util.RNG
+	// StartPixelSample prepares to generate sample index of pixel x, y.
+	StartPixelSample(x, y, index int)
+	// Get1D returns the next dimension.
+	Get1D() float64
+	// Get2D returns the next two dimensions, stratified together.
+	Get2D() (float64, float64)
+}
+
+// Kind selects a sampling strategy
+type Kind int
+
+const (
+	// Independent uses uniform random numbers for every dimension
+	Independent Kind = iota
+	// Stratified jitters samples within shuffled strata of each dimension
+	Stratified
+	// Halton uses the Halton sequence, with randomly permuted digits per pixel
+	Halton
+	// Sobol uses the Sobol sequence with per-pixel Owen scrambling
+	Sobol
+	// BlueNoise uses a Sobol sequence shared by every pixel, offset by a blue
+	// noise mask, so that the remaining error looks like fine grain rather than
+	// blotches
+	BlueNoise
+)
+
+var kindNames = map[string]Kind{
+	"independent": Independent,
+	"stratified":  Stratified,
+	"halton":      Halton,
+	"sobol":       Sobol,
+	"bluenoise":   BlueNoise,
+}
+
+// ParseKind returns the Kind with the given lowercase name, such as "sobol".
+func ParseKind(name string) (Kind, error) {
+	kind, ok := kindNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown sampler %q", name)
+	}
+	return kind, nil
+}
+
+// New creates a sampler of the given kind. samplesPerPixel is the expected
+// number of samples per pixel, which stratified sampling divides into strata.
+func New(kind Kind, samplesPerPixel int, seed uint64) (Sampler, error) {
+	switch kind {
+	case Independent:
+		return newIndependent(seed), nil
+	case Stratified:
+		return &stratified{samplesPerPixel: max(samplesPerPixel, 1), seed: seed, fallback: newIndependent(seed)}, nil
+	case Halton:
+		return &halton{seed: seed, fallback: newIndependent(seed)}, nil
+	case Sobol:
+		return &sobol{seed: seed}, nil
+	case BlueNoise:
+		return &blueNoise{seed: seed}, nil
+	}
+	return nil, fmt.Errorf("unknown sampler kind %d", kind)
+}
+
+// independent draws each dimension from a PCG stream reseeded for each pixel
+// sample, so results don't depend on the order pixels are rendered in.
+type independent struct {
+	seed uint64
+	pcg  *rand.PCG
+	rng  *rand.Rand
+}
+
+func newIndependent(seed uint64) *independent {
+	pcg := rand.NewPCG(seed, 0)
+	return &independent{seed: seed, pcg: pcg, rng: rand.New(pcg)}
+}
+
+func (s *independent) StartPixelSample(x, y, index int) {
+	s.pcg.Seed(s.seed, util.Hash(uint64(x), uint64(y), uint64(index)))
+}
+
+func (s *independent) Get1D() float64 {
+	return s.rng.Float64()
+}
+
+func (s *independent) Get2D() (float64, float64) {
+	return s.rng.Float64(), s.rng.Float64()
+}
+
+func (s *independent) Float64() float64 {
+	return s.Get1D()
+}
+
+// maxDimensions bounds how many well-distributed dimensions the structured
+// samplers provide. Paths deeper than this rarely matter much, and fall back
+// to independent random numbers.
+const maxDimensions = 64
+
+// pixelState holds what the structured samplers track for the current sample.
+type pixelState struct {
+	x, y, index int
+	dimension   int
+}
+
+func (p *pixelState) start(x, y, index int) {
+	p.x, p.y, p.index = x, y, index
+	p.dimension = 0
+}
+
+// hash returns a per-pixel, per-dimension hash of the seed.
+func (p *pixelState) hash(seed uint64, dimension int) uint64 {
+	return util.Hash(seed, uint64(p.x), uint64(p.y), uint64(dimension))
+}
+
+// toFloat converts a 32-bit fixed point fraction to a float in [0,1).
+func toFloat(x uint32) float64 {
+	return float64(x) / (1 << 32)
+}
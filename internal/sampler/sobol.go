@@ -0,0 +1,88 @@
+package sampler
+
+import (
+	"math/bits"
+)
+
+// sobol generates pairs of dimensions from the first two dimensions of the
+// Sobol sequence, which are well stratified together. Each pair is Owen
+// scrambled and has its sample order shuffled independently, following
+// Burley's "Practical Hash-based Owen Scrambling".
+// https://jcgt.org/published/0009/04/01/
+type sobol struct {
+	pixelState
+	seed uint64
+}
+
+func (s *sobol) StartPixelSample(x, y, index int) {
+	s.start(x, y, index)
+}
+
+func (s *sobol) Get1D() float64 {
+	d := s.dimension
+	s.dimension++
+	seed := uint32(s.hash(s.seed, d))
+	index := nestedUniformScramble(uint32(s.index), seed)
+	return toFloat(nestedUniformScramble(sobol0(index), hashUint32(seed, 1)))
+}
+
+func (s *sobol) Get2D() (float64, float64) {
+	d := s.dimension
+	s.dimension += 2
+	return shuffledScrambledSobol2D(uint32(s.index), uint32(s.hash(s.seed, d)))
+}
+
+func (s *sobol) Float64() float64 {
+	return s.Get1D()
+}
+
+// shuffledScrambledSobol2D returns an Owen scrambled point from the first two
+// Sobol dimensions, visiting the points in a shuffled order.
+func shuffledScrambledSobol2D(index, seed uint32) (float64, float64) {
+	index = nestedUniformScramble(index, seed)
+	x := nestedUniformScramble(sobol0(index), hashUint32(seed, 1))
+	y := nestedUniformScramble(sobol1(index), hashUint32(seed, 2))
+	return toFloat(x), toFloat(y)
+}
+
+// sobol0 is the first Sobol dimension, the base-2 van der Corput sequence.
+func sobol0(index uint32) uint32 {
+	return bits.Reverse32(index)
+}
+
+// sobol1 is the second Sobol dimension, whose generator matrix columns are
+// each the previous column xor'd with itself shifted right by one.
+func sobol1(index uint32) uint32 {
+	result := uint32(0)
+	for v := uint32(1 << 31); index != 0; index >>= 1 {
+		if index&1 != 0 {
+			result ^= v
+		}
+		v ^= v >> 1
+	}
+	return result
+}
+
+// nestedUniformScramble performs an Owen scramble of x's bits, approximated by
+// a hash that only lets higher bits affect lower ones.
+func nestedUniformScramble(x, seed uint32) uint32 {
+	return bits.Reverse32(laineKarrasPermutation(bits.Reverse32(x), seed))
+}
+
+func laineKarrasPermutation(x, seed uint32) uint32 {
+	x += seed
+	x ^= x * 0x6c50b47c
+	x ^= x * 0xb82f1e52
+	x ^= x * 0xc7afe638
+	x ^= x * 0x8d22f6e6
+	return x
+}
+
+// hashUint32 derives a new 32-bit seed from a seed and a value.
+func hashUint32(seed, value uint32) uint32 {
+	h := uint64(seed)<<32 | uint64(value)
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return uint32(h)
+}
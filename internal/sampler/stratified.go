@@ -0,0 +1,78 @@
+package sampler
+
+// stratified splits each dimension into samplesPerPixel strata and places one
+// jittered sample in each. The strata are visited in a different random order
+// for every dimension of every pixel. Pairs of dimensions are stratified as a
+// Latin hypercube, so each sample falls in a distinct row and column.
+type stratified struct {
+	pixelState
+	samplesPerPixel int
+	seed            uint64
+	fallback        *independent
+}
+
+func (s *stratified) StartPixelSample(x, y, index int) {
+	s.start(x, y, index)
+	s.fallback.StartPixelSample(x, y, index)
+}
+
+func (s *stratified) Get1D() float64 {
+	if s.dimension >= maxDimensions || s.index >= s.samplesPerPixel {
+		return s.fallback.Get1D()
+	}
+	d := s.dimension
+	s.dimension++
+	return s.jittered(d)
+}
+
+func (s *stratified) Get2D() (float64, float64) {
+	return s.Get1D(), s.Get1D()
+}
+
+func (s *stratified) Float64() float64 {
+	return s.Get1D()
+}
+
+// jittered returns a random point within this sample's stratum of dimension d.
+func (s *stratified) jittered(d int) float64 {
+	h := s.hash(s.seed, d)
+	stratum := permutationElement(uint32(s.index), uint32(s.samplesPerPixel), uint32(h))
+	return (float64(stratum) + s.fallback.Get1D()) / float64(s.samplesPerPixel)
+}
+
+// permutationElement returns the i-th element of a random permutation of
+// [0, n) chosen by seed, without storing the permutation. It applies an
+// invertible hash to the bits covering n, cycling until the result is in range.
+// https://graphics.pixar.com/library/MultiJitteredSampling/paper.pdf
+func permutationElement(i, n, seed uint32) uint32 {
+	w := n - 1
+	w |= w >> 1
+	w |= w >> 2
+	w |= w >> 4
+	w |= w >> 8
+	w |= w >> 16
+	for {
+		i ^= seed
+		i *= 0xe170893d
+		i ^= seed >> 16
+		i ^= (i & w) >> 4
+		i ^= seed >> 8
+		i *= 0x0929eb3f
+		i ^= seed >> 23
+		i ^= (i & w) >> 1
+		i *= 1 | seed>>27
+		i *= 0x6935fa69
+		i ^= (i & w) >> 11
+		i *= 0x74dcb303
+		i ^= (i & w) >> 2
+		i *= 0x9e501cc3
+		i ^= (i & w) >> 2
+		i *= 0xc860a3df
+		i &= w
+		i ^= i >> 5
+		if i < n {
+			break
+		}
+	}
+	return (i + seed) % n
+}
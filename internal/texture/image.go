@@ -0,0 +1,99 @@
+package texture
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"raytracer/internal/color"
+	"raytracer/internal/interval"
+	"raytracer/internal/vector"
+)
+
+// imageChannels selects how an image's pixels are interpreted.
+type imageChannels int
+
+const (
+	gammaColor  imageChannels = iota // Gamma encoded color
+	linearColor                      // Raw data, used as is
+	alphaOnly                        // The alpha channel, repeated in each component
+)
+
+// Image is a texture backed by an image, looked up by surface coordinates.
+type Image struct {
+	img      image.Image
+	channels imageChannels
+}
+
+// NewImage creates a texture from a gamma encoded image, such as a photo or a
+// painted albedo map.
+func NewImage(img image.Image) Image {
+	return Image{img, gammaColor}
+}
+
+// NewImageData creates a texture from an image holding raw data, such as a
+// normal or height map, whose values must not be gamma decoded.
+func NewImageData(img image.Image) Image {
+	return Image{img, linearColor}
+}
+
+// NewImageAlpha creates a texture from an image's alpha channel, for use as an
+// opacity mask.
+func NewImageAlpha(img image.Image) Image {
+	return Image{img, alphaOnly}
+}
+
+// LoadImage reads a PNG or JPEG file from disk.
+func LoadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+	}
+	return img, nil
+}
+
+func (t Image) Value(u, v float64, p vector.Point3) color.Color {
+	bounds := t.img.Bounds()
+	if bounds.Empty() {
+		// Return solid cyan as a debugging aid for missing images.
+		return color.NewColor(0, 1, 1)
+	}
+
+	// Clamp input texture coordinates to [0,1] x [1,0]. Image rows run top to
+	// bottom, so v is flipped.
+	unit := interval.NewInterval(0, 1)
+	u = unit.Clamp(u)
+	v = 1.0 - unit.Clamp(v)
+
+	i := bounds.Min.X + min(int(u*float64(bounds.Dx())), bounds.Dx()-1)
+	j := bounds.Min.Y + min(int(v*float64(bounds.Dy())), bounds.Dy()-1)
+
+	r, g, b, a := t.img.At(i, j).RGBA()
+	switch t.channels {
+	case alphaOnly:
+		alpha := float64(a) / 0xffff
+		return color.NewColor(alpha, alpha, alpha)
+	case linearColor:
+		return rgbaToColor(r, g, b, a)
+	default:
+		// Undo the gamma 2 encoding applied when writing colors.
+		c := rgbaToColor(r, g, b, a)
+		return c.Mul(c)
+	}
+}
+
+// rgbaToColor converts alpha-premultiplied 16-bit components, as returned by
+// the image package, to a straight color in [0,1].
+func rgbaToColor(r, g, b, a uint32) color.Color {
+	if a == 0 {
+		return color.NewColor(0, 0, 0)
+	}
+	return color.NewColor(float64(r)/float64(a), float64(g)/float64(a), float64(b)/float64(a))
+}
@@ -0,0 +1,24 @@
+package texture
+
+import (
+	"raytracer/internal/color"
+	"raytracer/internal/vector"
+)
+
+// Texture maps a surface coordinate to a color.
+type Texture interface {
+	Value(u, v float64, p vector.Point3) color.Color
+}
+
+// SolidColor is a texture with the same color everywhere.
+type SolidColor struct {
+	albedo color.Color
+}
+
+func NewSolidColor(albedo color.Color) SolidColor {
+	return SolidColor{albedo}
+}
+
+func (s SolidColor) Value(u, v float64, p vector.Point3) color.Color {
+	return s.albedo
+}
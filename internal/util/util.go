@@ -2,19 +2,75 @@ package util
 
 import (
 	"math"
-	"math/rand"
+	"math/rand/v2"
 )
 
 func DegreesToRadians(degrees float64) float64 {
 	return degrees * math.Pi / 180.0
 }
 
+// RNG is a source of uniformly distributed random numbers. Each goroutine
+// should use its own, since generators aren't safe for concurrent use.
+type RNG interface {
+	// Returns a random real in [0,1)
+	Float64() float64
+}
+
+// NewRNG returns a generator whose sequence is fully determined by seed and
+// stream. Different streams from the same seed are independent, so work can be
+// split up without changing the numbers each part sees.
+func NewRNG(seed, stream uint64) RNG {
+	return rand.New(rand.NewPCG(seed, stream))
+}
+
 // Returns a random real in [0,1)
-func RandomFloat() float64 {
-	return rand.Float64()
+func RandomFloat(rng RNG) float64 {
+	return rng.Float64()
+}
+
+// RNG2D is implemented by generators that can draw correlated 2D samples, such
+// as low-discrepancy samplers whose dimensions are only well distributed when
+// taken as a pair.
+type RNG2D interface {
+	Get2D() (float64, float64)
+}
+
+// Returns a random point in [0,1)^2, drawn as a single 2D sample when the
+// generator supports it
+func Random2D(rng RNG) (float64, float64) {
+	if r, ok := rng.(RNG2D); ok {
+		return r.Get2D()
+	}
+	u := rng.Float64()
+	return u, rng.Float64()
 }
 
 // Returns a random float in [min, max)
-func RandomFloatFromRange(min, max float64) float64 {
-	return min + (max-min)*RandomFloat()
+func RandomFloatFromRange(rng RNG, min, max float64) float64 {
+	return min + (max-min)*RandomFloat(rng)
+}
+
+// Returns a pseudo-random float in [0,1) derived deterministically from values
+func HashFloat(values ...float64) float64 {
+	bits := make([]uint64, len(values))
+	for i, v := range values {
+		bits[i] = math.Float64bits(v)
+	}
+	return float64(Hash(bits...)>>11) / (1 << 53)
+}
+
+// Returns a well-mixed hash of values
+func Hash(values ...uint64) uint64 {
+	// FNV-1a over each value, followed by a final avalanche mix.
+	h := uint64(14695981039346656037)
+	for _, v := range values {
+		h ^= v
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
 }
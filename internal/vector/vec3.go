@@ -96,27 +96,32 @@ func Cross(u, v Vec3) Vec3 {
 	)
 }
 
-func Random() Vec3 {
-	return NewVec3(util.RandomFloat(), util.RandomFloat(), util.RandomFloat())
+func Random(rng util.RNG) Vec3 {
+	return NewVec3(util.RandomFloat(rng), util.RandomFloat(rng), util.RandomFloat(rng))
 }
 
-func RandomFromRange(min, max float64) Vec3 {
-	return NewVec3(util.RandomFloatFromRange(min, max), util.RandomFloatFromRange(min, max), util.RandomFloatFromRange(min, max))
+func RandomFromRange(rng util.RNG, min, max float64) Vec3 {
+	return NewVec3(util.RandomFloatFromRange(rng, min, max), util.RandomFloatFromRange(rng, min, max), util.RandomFloatFromRange(rng, min, max))
 }
 
-func RandomUnitVector() Vec3 {
-	for {
-		p := RandomFromRange(-1.0, 1.0)
-		lenSq := p.LengthSquared()
-		// Very small values can underflow to 0 when squared, so add a lower bound.
-		if 1e-160 < lenSq && lenSq <= 1 {
-			return p.Div(math.Sqrt(lenSq))
-		}
-	}
+// RandomUnitVector returns a uniformly distributed direction. It maps a single
+// 2D sample, rather than rejection sampling, so that low-discrepancy samplers
+// keep their structure.
+func RandomUnitVector(rng util.RNG) Vec3 {
+	return SphereFromSquare(util.Random2D(rng))
+}
+
+// SphereFromSquare maps a point in the unit square to the unit sphere,
+// preserving relative areas.
+func SphereFromSquare(u, v float64) Vec3 {
+	z := 1 - 2*u
+	r := math.Sqrt(math.Max(0, 1-z*z))
+	phi := 2 * math.Pi * v
+	return NewVec3(r*math.Cos(phi), r*math.Sin(phi), z)
 }
 
-func RandomOnHemisphere(normal Vec3) Vec3 {
-	onUnitSphere := RandomUnitVector()
+func RandomOnHemisphere(rng util.RNG, normal Vec3) Vec3 {
+	onUnitSphere := RandomUnitVector(rng)
 	if Dot(onUnitSphere, normal) > 0.0 { // In the same hemisphere as the normal
 		return onUnitSphere
 	} else {
@@ -124,13 +129,25 @@ func RandomOnHemisphere(normal Vec3) Vec3 {
 	}
 }
 
-func RandomInUnitDisk() Vec3 {
-	for {
-		p := NewVec3(util.RandomFloatFromRange(-1, 1), util.RandomFloatFromRange(-1, 1), 0)
-		if p.LengthSquared() < 1 {
-			return p
-		}
+func RandomInUnitDisk(rng util.RNG) Vec3 {
+	return DiskFromSquare(util.Random2D(rng))
+}
+
+// DiskFromSquare maps a point in the unit square to the unit disk using
+// Shirley's concentric mapping, which keeps nearby points close together.
+func DiskFromSquare(u, v float64) Vec3 {
+	a, b := 2*u-1, 2*v-1
+	if a == 0 && b == 0 {
+		return ZeroVec3()
+	}
+
+	var r, theta float64
+	if math.Abs(a) > math.Abs(b) {
+		r, theta = a, math.Pi/4*(b/a)
+	} else {
+		r, theta = b, math.Pi/2-math.Pi/4*(a/b)
 	}
+	return NewVec3(r*math.Cos(theta), r*math.Sin(theta), 0)
 }
 
 // The reflected ray direction in red is just 𝐯+2𝐛. In our design, 𝐧 is a unit